@@ -0,0 +1,94 @@
+package tvsocket
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// unauthorizedToken is sent without a TokenProvider, it limits the socket to delayed data and a shorter history
+const unauthorizedToken = "unauthorized_user_token"
+
+// tokenRefreshMargin is how long before its expiry a token is replaced
+const tokenRefreshMargin = time.Minute
+
+// minTokenRefresh bounds the pace of the refreshes, a provider returning a token about to expire is not called in a loop
+const minTokenRefresh = time.Second
+
+// TokenProvider supplies the auth token sent with set_auth_token.
+// It is called on every connection, reconnects included, and again shortly before the token expires;
+// a zero expires means the token does not expire.
+type TokenProvider interface {
+	Token(ctx context.Context) (token string, expires time.Time, err error)
+}
+
+// TokenProviderFunc adapts a function to TokenProvider
+type TokenProviderFunc func(ctx context.Context) (token string, expires time.Time, err error)
+
+// Token calls f
+func (f TokenProviderFunc) Token(ctx context.Context) (string, time.Time, error) {
+	return f(ctx)
+}
+
+// staticToken is the provider set by WithAuthToken
+type staticToken string
+
+func (t staticToken) Token(context.Context) (string, time.Time, error) {
+	return string(t), time.Time{}, nil
+}
+
+// authToken asks the provider for a token, the unauthorized token is used without provider.
+// A token that has already expired fails with ErrAuth.
+func (s *Socket) authToken(ctx context.Context) (token string, expires time.Time, err error) {
+	if s.config.tokenProvider == nil {
+		return unauthorizedToken, time.Time{}, nil
+	}
+	token, expires, err = s.config.tokenProvider.Token(ctx)
+	if err != nil {
+		return "", time.Time{}, newError(ErrAuth, AuthTokenErrorContext, err)
+	}
+	if !expires.IsZero() && !expires.After(time.Now()) {
+		return "", time.Time{}, newError(ErrAuth, AuthTokenErrorContext, fmt.Errorf("token expired at %v", expires))
+	}
+	return
+}
+
+// scheduleTokenRefresh arms the timer sending a fresh token before expires, no sooner than minTokenRefresh.
+// A zero expires disarms it.
+func (s *Socket) scheduleTokenRefresh(expires time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tokenTimer != nil {
+		s.tokenTimer.Stop()
+		s.tokenTimer = nil
+	}
+	if expires.IsZero() {
+		return
+	}
+	left := time.Until(expires)
+	s.tokenTimer = time.AfterFunc(max(left-min(tokenRefreshMargin, left/2), minTokenRefresh), s.refreshToken)
+}
+
+// refreshToken sends set_auth_token with a fresh token on the current connection.
+// A failure of the provider is reported to OnErrorCallback, the connection is left open until the server rejects it.
+func (s *Socket) refreshToken() {
+	if s.closed() {
+		return
+	}
+	token, expires, err := s.authToken(s.ctx)
+	if err != nil {
+		if s.closed() {
+			return
+		}
+		if s.OnErrorCallback == nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		s.OnErrorCallback(err, AuthTokenErrorContext)
+		return
+	}
+	if err = s.sendSocketMessage(getSocketMessage("set_auth_token", []string{token})); err != nil {
+		return
+	}
+	s.scheduleTokenRefresh(expires)
+}
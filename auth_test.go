@@ -0,0 +1,196 @@
+package tvsocket
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ivo100/tvsocket/tvtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSocket_AuthToken(t *testing.T) {
+	server := tvtest.NewServer(t)
+	tv, err := ConnectWithOptions(context.Background(), WithURL(server.URL()), WithAuthToken("secret"))
+	require.NoError(t, err)
+	defer tv.Close()
+
+	m := server.WaitFor(t, "set_auth_token")
+	require.Equal(t, []any{"secret"}, m.Payload)
+}
+
+func TestSocket_UnauthorizedToken(t *testing.T) {
+	server := tvtest.NewServer(t)
+	tv, err := ConnectWithOptions(context.Background(), WithURL(server.URL()))
+	require.NoError(t, err)
+	defer tv.Close()
+
+	m := server.WaitFor(t, "set_auth_token")
+	require.Equal(t, []any{"unauthorized_user_token"}, m.Payload)
+}
+
+func TestSocket_TokenProviderRefreshesAndReconnects(t *testing.T) {
+	server := tvtest.NewServer(t)
+
+	var mu sync.Mutex
+	calls := 0
+	provider := TokenProviderFunc(func(ctx context.Context) (string, time.Time, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		expires := time.Now().Add(time.Hour)
+		if calls == 1 {
+			// the first token is replaced halfway through its short life
+			expires = time.Now().Add(2 * time.Second)
+		}
+		return "token-" + strconv.Itoa(calls), expires, nil
+	})
+	reconnected := make(chan int, 1)
+	tv, err := ConnectWithOptions(context.Background(),
+		WithURL(server.URL()),
+		WithTokenProvider(provider),
+		WithReconnect(&ReconnectPolicy{InitialBackoff: 10 * time.Millisecond}, func(attempt int) {
+			reconnected <- attempt
+		}),
+	)
+	require.NoError(t, err)
+	defer tv.Close()
+
+	m := server.WaitFor(t, "set_auth_token")
+	require.Equal(t, []any{"token-1"}, m.Payload)
+	m = server.WaitFor(t, "set_auth_token")
+	require.Equal(t, []any{"token-2"}, m.Payload)
+
+	server.Disconnect()
+	select {
+	case <-reconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("socket did not reconnect")
+	}
+	m = server.WaitFor(t, "set_auth_token")
+	require.Equal(t, []any{"token-3"}, m.Payload)
+}
+
+func TestSocket_CachedTokenExpires(t *testing.T) {
+	server := tvtest.NewServer(t)
+
+	// the provider keeps returning the same token until it expires
+	var calls atomic.Int32
+	expires := time.Now().Add(200 * time.Millisecond)
+	provider := TokenProviderFunc(func(ctx context.Context) (string, time.Time, error) {
+		calls.Add(1)
+		return "cached", expires, nil
+	})
+	reported := make(chan error, 1)
+	tv, err := ConnectWithOptions(context.Background(),
+		WithURL(server.URL()),
+		WithTokenProvider(provider),
+		WithOnError(func(err error, context string) {
+			select {
+			case reported <- err:
+			default:
+			}
+		}),
+	)
+	require.NoError(t, err)
+	defer tv.Close()
+
+	select {
+	case err := <-reported:
+		require.ErrorIs(t, err, ErrAuth)
+	case <-time.After(5 * time.Second):
+		t.Fatal("expired token was not reported")
+	}
+	time.Sleep(2 * minTokenRefresh)
+	require.Equal(t, int32(2), calls.Load())
+
+	_, err = ConnectWithOptions(context.Background(), WithURL(server.URL()), WithTokenProvider(provider))
+	require.ErrorIs(t, err, ErrAuth)
+}
+
+func TestSocket_TokenProviderError(t *testing.T) {
+	server := tvtest.NewServer(t)
+	provider := TokenProviderFunc(func(ctx context.Context) (string, time.Time, error) {
+		return "", time.Time{}, errors.New("no session")
+	})
+	_, err := ConnectWithOptions(context.Background(), WithURL(server.URL()), WithTokenProvider(provider))
+	require.ErrorIs(t, err, ErrAuth)
+	require.False(t, IsRetryable(err))
+}
+
+func TestSocket_AuthErrors(t *testing.T) {
+	server := tvtest.NewServer(t)
+	server.SetSymbolError("CME:ES1!", "permission denied")
+	reported := make(chan error, 1)
+	tv, err := ConnectWithOptions(context.Background(),
+		WithURL(server.URL()),
+		WithAuthToken("expired"),
+		WithOnError(func(err error, context string) {
+			select {
+			case reported <- err:
+			default:
+			}
+		}),
+	)
+	require.NoError(t, err)
+	defer tv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = tv.GetBars(ctx, "CME:ES1!", "1D", 10)
+	require.ErrorIs(t, err, ErrPermissionDenied)
+	require.NotErrorIs(t, err, ErrSymbolNotFound)
+	require.False(t, IsRetryable(err))
+
+	server.SendError("protocol_error", "invalid auth token")
+	select {
+	case err := <-reported:
+		require.ErrorIs(t, err, ErrAuth)
+		require.ErrorIs(t, err, ErrServerError)
+		var serverErr *ServerError
+		require.ErrorAs(t, err, &serverErr)
+		require.Equal(t, "protocol_error", serverErr.Type)
+	case <-time.After(5 * time.Second):
+		t.Fatal("protocol_error was not reported")
+	}
+}
+
+func TestServerError_Auth(t *testing.T) {
+	require.ErrorIs(t, &ServerError{Type: "protocol_error", Payload: []any{"invalid auth token"}}, ErrAuth)
+	require.ErrorIs(t, &ServerError{Type: "critical_error", Payload: []any{"cs_x", "invalid_token"}}, ErrAuth)
+	err := &ServerError{Type: "protocol_error", Payload: []any{"wrong data"}}
+	require.NotErrorIs(t, err, ErrAuth)
+	require.ErrorIs(t, err, ErrServerError)
+	// neither the session id nor the symbols are the reason
+	err = &ServerError{Type: "critical_error", Payload: []any{"cs_authtoken1", "invalid_parameters", "AUTH:TOKEN"}}
+	require.NotErrorIs(t, err, ErrAuth)
+	require.NotErrorIs(t, &ServerError{Type: "critical_error", Payload: []any{"cs_x", "author mismatch"}}, ErrAuth)
+}
+
+func TestServerError_PermissionDenied(t *testing.T) {
+	err := &ServerError{Type: "critical_error", Payload: []any{"cs_x", "permission denied"}}
+	require.ErrorIs(t, err, ErrPermissionDenied)
+	require.NotErrorIs(t, err, ErrAuth)
+	require.NotErrorIs(t, &ServerError{Type: "critical_error", Payload: []any{"cs_x", "oops"}}, ErrPermissionDenied)
+}
+
+func TestSocket_CloseContextStopsTokenRefresh(t *testing.T) {
+	server := tvtest.NewServer(t)
+	provider := TokenProviderFunc(func(ctx context.Context) (string, time.Time, error) {
+		return "token", time.Now().Add(time.Hour), nil
+	})
+	tv, err := ConnectWithOptions(context.Background(), WithURL(server.URL()), WithTokenProvider(provider))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, tv.CloseContext(ctx))
+	tv.mu.Lock()
+	defer tv.mu.Unlock()
+	// Stop reports false once the timer has been stopped
+	require.False(t, tv.tokenTimer.Stop())
+}
@@ -0,0 +1,154 @@
+package tvsocket
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// rangePageSize is the number of bars GetBarsRange requests at a time
+const rangePageSize = 500
+
+// seriesStatus is decoded from series_completed, series_error and symbol_error messages
+type seriesStatus struct {
+	seriesID string
+	// turnaround is the request a series_completed answers
+	turnaround string
+	err        error
+}
+
+// GetBars requests count bars of the symbol and blocks until the whole history has been received.
+// A rejected symbol or series is reported as a *SeriesError.
+// Called from a callback it fails with ErrCalledFromCallback.
+func (s *Socket) GetBars(ctx context.Context, symbol string, interval string, count int) (bars []TOHLCV, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	if err = s.checkNotInLoop(); err != nil {
+		return
+	}
+	if interval, err = wireInterval(interval); err != nil {
+		return
+	}
+
+	var mu sync.Mutex
+	finished := false
+	series := s.newSeries(symbol, count, interval, func(symbol string, hloc []TOHLCV) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !finished {
+			bars = append(bars, hloc...)
+		}
+	})
+	s.mu.Lock()
+	series.oneShot = true
+	s.mu.Unlock()
+	defer series.Remove()
+
+	completed := series.wait()
+	if err = s.sendSeries(series); err != nil {
+		return nil, err
+	}
+	err = series.waitCompleted(ctx, completed)
+
+	mu.Lock()
+	defer mu.Unlock()
+	finished = true
+	if err != nil {
+		return nil, err
+	}
+	return bars, nil
+}
+
+// LoadMore asks the server for count bars older than the ones the series received.
+// They are delivered to the callback of the series like the rest of its history.
+func (s *Socket) LoadMore(series *Series, count int) error {
+	return s.sendSocketMessage(getSocketMessage("request_more_data", []any{s.chartSessionID, series.id, count}))
+}
+
+// loadMoreContext asks for older bars and waits until they have been delivered
+func (s *Socket) loadMoreContext(ctx context.Context, series *Series, count int) error {
+	completed := series.wait()
+	if err := s.LoadMore(series, count); err != nil {
+		s.removeWaiter(&series.waiters, completed)
+		return err
+	}
+	return series.waitCompleted(ctx, completed)
+}
+
+// GetBarsRange returns the bars of the symbol between from and to, oldest first.
+// It pages back through the history with request_more_data until from is covered or the server has no older bars,
+// the bars received twice are kept once. A zero to means up to the last bar.
+// Called from a callback it fails with ErrCalledFromCallback.
+func (s *Socket) GetBarsRange(ctx context.Context, symbol string, interval string, from time.Time, to time.Time) (bars []TOHLCV, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	if err = s.checkNotInLoop(); err != nil {
+		return
+	}
+	if interval, err = wireInterval(interval); err != nil {
+		return
+	}
+
+	var mu sync.Mutex
+	byTime := make(map[int64]TOHLCV)
+	series := s.newSeries(symbol, rangePageSize, interval, func(symbol string, hloc []TOHLCV) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, bar := range hloc {
+			byTime[bar.Time] = bar
+		}
+	})
+	s.mu.Lock()
+	series.oneShot = true
+	s.mu.Unlock()
+	defer series.Remove()
+
+	// received returns the number of distinct bars and the time of the oldest one
+	received := func() (n int, oldest int64) {
+		mu.Lock()
+		defer mu.Unlock()
+		for t := range byTime {
+			if n == 0 || t < oldest {
+				oldest = t
+			}
+			n++
+		}
+		return
+	}
+
+	completed := series.wait()
+	if err = s.sendSeries(series); err != nil {
+		return nil, err
+	}
+	if err = series.waitCompleted(ctx, completed); err != nil {
+		return nil, err
+	}
+	for {
+		n, oldest := received()
+		if n == 0 || oldest <= from.Unix() {
+			break
+		}
+		if err = s.loadMoreContext(ctx, series, rangePageSize); err != nil {
+			return nil, err
+		}
+		// a page without new bars means the history has been exhausted
+		if more, _ := received(); more == n {
+			break
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for t, bar := range byTime {
+		if t >= from.Unix() && (to.IsZero() || t <= to.Unix()) {
+			bars = append(bars, bar)
+		}
+	}
+	sort.Slice(bars, func(i, j int) bool {
+		return bars[i].Time < bars[j].Time
+	})
+	return bars, nil
+}
@@ -0,0 +1,194 @@
+package tvsocket
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ivo100/tvsocket/tvtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSocket_GetBars(t *testing.T) {
+	server := tvtest.NewServer(t)
+	tv, err := ConnectWithOptions(context.Background(), WithURL(server.URL()))
+	require.NoError(t, err)
+	defer tv.Close()
+
+	go func() {
+		server.WaitFor(t, "create_series")
+		server.Send(
+			`{"m":"timescale_update","p":["cs_x",{"sds_1":{"s":[{"i":0,"v":[1716290100.0,426.65,426.83,426.65,426.8,458.0]}]}}]}`,
+			`{"m":"timescale_update","p":["cs_x",{"sds_1":{"s":[{"i":1,"v":[1716290400.0,426.7,426.83,426.7,426.83,108.0]}]}}]}`,
+			`{"m":"series_completed","p":["cs_x","sds_1","streaming","s1",{"rt_update_period":0}]}`,
+		)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	bars, err := tv.GetBars(ctx, "NASDAQ:MSFT", "5", 2)
+	require.NoError(t, err)
+	require.Equal(t, []TOHLCV{
+		{Time: 1716290100, Open: 426.65, High: 426.83, Low: 426.65, Close: 426.8, Volume: 458},
+		{Time: 1716290400, Open: 426.7, High: 426.83, Low: 426.7, Close: 426.83, Volume: 108},
+	}, bars)
+
+	m := server.WaitFor(t, "remove_series")
+	require.Equal(t, "sds_1", m.Payload[1])
+}
+
+func TestSocket_GetBarsSymbolError(t *testing.T) {
+	server := tvtest.NewServer(t)
+	tv, err := ConnectWithOptions(context.Background(), WithURL(server.URL()))
+	require.NoError(t, err)
+	defer tv.Close()
+
+	go func() {
+		server.WaitFor(t, "create_series")
+		server.Send(`{"m":"symbol_error","p":["cs_x","sds_sym_1","invalid symbol"]}`)
+	}()
+
+	_, err = tv.GetBars(context.Background(), "NASDAQ:NOPE", "5", 2)
+	require.ErrorIs(t, err, ErrSymbolNotFound)
+	require.False(t, IsRetryable(err))
+	var seriesErr *SeriesError
+	require.ErrorAs(t, err, &seriesErr)
+	require.Equal(t, "NASDAQ:NOPE", seriesErr.Symbol)
+	require.Equal(t, "invalid symbol", seriesErr.Message)
+}
+
+func TestSocket_GetBarsContext(t *testing.T) {
+	server := tvtest.NewServer(t)
+	tv, err := ConnectWithOptions(context.Background(), WithURL(server.URL()))
+	require.NoError(t, err)
+	defer tv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = tv.GetBars(ctx, "NASDAQ:MSFT", "5", 2)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestSocket_GetBarsConnectionLost(t *testing.T) {
+	server := tvtest.NewServer(t)
+	tv, err := ConnectWithOptions(context.Background(), WithURL(server.URL()))
+	require.NoError(t, err)
+	defer tv.Close()
+
+	go func() {
+		server.WaitFor(t, "create_series")
+		server.Disconnect()
+	}()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := tv.GetBars(context.Background(), "NASDAQ:MSFT", "5", 2)
+		done <- err
+	}()
+	select {
+	case err = <-done:
+		require.ErrorIs(t, err, ErrConnectionClosed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("GetBars did not return after the connection was lost")
+	}
+
+	_, err = tv.ResolveSymbol(context.Background(), "NASDAQ:MSFT")
+	require.ErrorIs(t, err, ErrConnectionClosed)
+}
+
+func TestSocket_GetBarsRange(t *testing.T) {
+	server := tvtest.NewServer(t)
+	var history []tvtest.Bar
+	for i := 0; i < 1200; i++ {
+		history = append(history, tvtest.Bar{Time: 1716290100 + int64(i)*300, Open: 1, High: 2, Low: 0.5, Close: float64(i), Volume: 100})
+	}
+	server.SetBars("NASDAQ:MSFT", history...)
+	tv, err := ConnectWithOptions(context.Background(), WithURL(server.URL()))
+	require.NoError(t, err)
+	defer tv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	from, to := time.Unix(history[100].Time, 0), time.Unix(history[1100].Time, 0)
+	bars, err := tv.GetBarsRange(ctx, "NASDAQ:MSFT", "5", from, to)
+	require.NoError(t, err)
+	require.Len(t, bars, 1001)
+	for i, bar := range bars {
+		require.Equal(t, history[100+i].Time, bar.Time)
+	}
+	server.WaitFor(t, "create_series")
+	m := server.WaitFor(t, "request_more_data")
+	require.Equal(t, []any{tv.chartSessionID, "sds_1", float64(rangePageSize)}, m.Payload)
+	server.WaitFor(t, "request_more_data")
+
+	// the whole history is exhausted when from is older than the first bar
+	bars, err = tv.GetBarsRange(ctx, "NASDAQ:MSFT", "5", time.Unix(0, 0), time.Time{})
+	require.NoError(t, err)
+	require.Len(t, bars, 1200)
+}
+
+func TestSocket_GetBarsRangeDeduplicates(t *testing.T) {
+	server := tvtest.NewServer(t)
+	tv, err := ConnectWithOptions(context.Background(), WithURL(server.URL()))
+	require.NoError(t, err)
+	defer tv.Close()
+
+	go func() {
+		server.WaitFor(t, "create_series")
+		server.Send(
+			`{"m":"timescale_update","p":["cs_x",{"sds_1":{"s":[{"i":0,"v":[300,1,1,1,1,1]},{"i":1,"v":[400,1,1,1,1,1]}]}}]}`,
+			`{"m":"series_completed","p":["cs_x","sds_1","streaming","s1"]}`,
+		)
+		// the pages overlap, the last one brings nothing new
+		server.WaitFor(t, "request_more_data")
+		server.Send(
+			`{"m":"timescale_update","p":["cs_x",{"sds_1":{"s":[{"i":-2,"v":[200,1,1,1,1,1]},{"i":-1,"v":[300,2,2,2,2,2]}]}}]}`,
+			`{"m":"series_completed","p":["cs_x","sds_1","streaming","s1"]}`,
+		)
+		server.WaitFor(t, "request_more_data")
+		server.Send(
+			`{"m":"timescale_update","p":["cs_x",{"sds_1":{"s":[{"i":-3,"v":[200,1,1,1,1,1]}]}}]}`,
+			`{"m":"series_completed","p":["cs_x","sds_1","streaming","s1"]}`,
+		)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	bars, err := tv.GetBarsRange(ctx, "NASDAQ:MSFT", "5", time.Unix(100, 0), time.Time{})
+	require.NoError(t, err)
+	require.Equal(t, []TOHLCV{
+		{Time: 200, Open: 1, High: 1, Low: 1, Close: 1, Volume: 1},
+		{Time: 300, Open: 2, High: 2, Low: 2, Close: 2, Volume: 2},
+		{Time: 400, Open: 1, High: 1, Low: 1, Close: 1, Volume: 1},
+	}, bars)
+}
+
+func TestSocket_LoadMore(t *testing.T) {
+	server := tvtest.NewServer(t)
+	server.SetBars("NASDAQ:MSFT",
+		tvtest.Bar{Time: 1716290100, Close: 1},
+		tvtest.Bar{Time: 1716290400, Close: 2},
+		tvtest.Bar{Time: 1716290700, Close: 3},
+	)
+	tv, err := ConnectWithOptions(context.Background(), WithURL(server.URL()))
+	require.NoError(t, err)
+	defer tv.Close()
+
+	received := make(chan []TOHLCV, 2)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	series, err := tv.CreateSeriesContext(ctx, "NASDAQ:MSFT", 1, "5", func(symbol string, hloc []TOHLCV) {
+		received <- hloc
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3.0, (<-received)[0].Close)
+
+	require.NoError(t, tv.LoadMore(series, 2))
+	older := <-received
+	require.Len(t, older, 2)
+	require.Equal(t, 1.0, older[0].Close)
+	require.Len(t, series.Bars(), 3)
+	bar, ok := series.Bar(-2)
+	require.True(t, ok)
+	require.Equal(t, int64(1716290100), bar.Time)
+}
@@ -0,0 +1,175 @@
+package tvsocket
+
+import (
+	"context"
+	"sort"
+)
+
+// defaultBarWindow bounds the bars kept by a series when WithBarWindow is not set
+const defaultBarWindow = 1000
+
+// BarEventKind tells how a bar of a series changed
+type BarEventKind int
+
+const (
+	// BarBatch is the kind of the StreamBars events, which carry the bars of an update in Bars
+	BarBatch BarEventKind = iota
+	// BarOpened is a new bar, still forming
+	BarOpened
+	// BarUpdated is a revision of a bar already received
+	BarUpdated
+	// BarClosed is a final bar: a newer bar has been received after it
+	BarClosed
+)
+
+func (k BarEventKind) String() string {
+	switch k {
+	case BarBatch:
+		return "batch"
+	case BarOpened:
+		return "opened"
+	case BarUpdated:
+		return "updated"
+	case BarClosed:
+		return "closed"
+	}
+	return "unknown"
+}
+
+// indexedBar is a bar of the window along with its index in the series
+type indexedBar struct {
+	index int
+	bar   TOHLCV
+}
+
+// barStore is the rolling window of the last bars of a series, keyed by their index
+type barStore struct {
+	window int
+	// bars are sorted by index
+	bars []indexedBar
+	// open is set while the newest bar is forming
+	open bool
+}
+
+// apply merges the bars of an update and returns the resulting events.
+// The newest bar of the update is opened, the older new bars are final and only reported as closed.
+func (bs *barStore) apply(indexes []int, hloc []TOHLCV) (events []BarEvent) {
+	newest := noBarIndex
+	for _, index := range indexes {
+		newest = max(newest, index)
+	}
+	for i, bar := range hloc {
+		index := indexes[i]
+		if index == noBarIndex {
+			continue
+		}
+		pos := sort.Search(len(bs.bars), func(j int) bool {
+			return bs.bars[j].index >= index
+		})
+		switch {
+		case pos < len(bs.bars) && bs.bars[pos].index == index:
+			if bs.bars[pos].bar != bar {
+				bs.bars[pos].bar = bar
+				events = append(events, BarEvent{Kind: BarUpdated, Index: index, Bar: bar})
+			}
+		case pos == len(bs.bars):
+			if bs.open {
+				last := bs.bars[len(bs.bars)-1]
+				events = append(events, BarEvent{Kind: BarClosed, Index: last.index, Bar: last.bar})
+			}
+			bs.bars = append(bs.bars, indexedBar{index: index, bar: bar})
+			bs.open = index == newest
+			if bs.open {
+				events = append(events, BarEvent{Kind: BarOpened, Index: index, Bar: bar})
+			} else {
+				events = append(events, BarEvent{Kind: BarClosed, Index: index, Bar: bar})
+			}
+		default:
+			bs.bars = append(bs.bars, indexedBar{})
+			copy(bs.bars[pos+1:], bs.bars[pos:])
+			bs.bars[pos] = indexedBar{index: index, bar: bar}
+			events = append(events, BarEvent{Kind: BarClosed, Index: index, Bar: bar})
+		}
+	}
+	window := bs.window
+	if window <= 0 {
+		window = defaultBarWindow
+	}
+	if extra := len(bs.bars) - window; extra > 0 {
+		bs.bars = append(bs.bars[:0], bs.bars[extra:]...)
+	}
+	return
+}
+
+func (bs *barStore) reset() {
+	bs.bars = nil
+	bs.open = false
+}
+
+// Bars returns the rolling window of the series, oldest first.
+// It holds the last bars received, up to the WithBarWindow size.
+func (sr *Series) Bars() []TOHLCV {
+	sr.socket.mu.Lock()
+	defer sr.socket.mu.Unlock()
+	bars := make([]TOHLCV, len(sr.store.bars))
+	for i, b := range sr.store.bars {
+		bars[i] = b.bar
+	}
+	return bars
+}
+
+// Bar returns the bar of the window at the index of the series
+func (sr *Series) Bar(index int) (TOHLCV, bool) {
+	sr.socket.mu.Lock()
+	defer sr.socket.mu.Unlock()
+	bars := sr.store.bars
+	pos := sort.Search(len(bars), func(j int) bool {
+		return bars[j].index >= index
+	})
+	if pos == len(bars) || bars[pos].index != index {
+		return TOHLCV{}, false
+	}
+	return bars[pos].bar, true
+}
+
+// LastIndex returns the index of the newest bar of the window, false while it is empty
+func (sr *Series) LastIndex() (int, bool) {
+	sr.socket.mu.Lock()
+	defer sr.socket.mu.Unlock()
+	if len(sr.store.bars) == 0 {
+		return 0, false
+	}
+	return sr.store.bars[len(sr.store.bars)-1].index, true
+}
+
+// StreamBarEvents creates a series for the symbol and returns a channel telling which bars opened, changed and closed.
+// The history is reported as closed bars, except for the newest one which is still forming.
+// The channel is closed and the series removed when ctx is done or the socket is closed.
+func (s *Socket) StreamBarEvents(ctx context.Context, symbol string, interval string) (<-chan BarEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	interval, err := wireInterval(interval)
+	if err != nil {
+		return nil, err
+	}
+	bars := s.config.streamHistory
+	if bars <= 0 {
+		bars = defaultStreamHistory
+	}
+	st := newStream[BarEvent](&s.config)
+	// the bars are only delivered as events, not to OnReceiveQuoteCallback
+	series := s.newSeries(symbol, bars, interval, func(string, []TOHLCV) {})
+	s.mu.Lock()
+	series.onBarEvent = st.publish
+	s.mu.Unlock()
+	if err := s.sendSeries(series); err != nil {
+		_ = series.Remove()
+		st.close()
+		return nil, err
+	}
+	s.closeWhenDone(ctx, st.close, func() {
+		_ = series.Remove()
+	})
+	return st.ch, nil
+}
@@ -0,0 +1,133 @@
+package tvsocket
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ivo100/tvsocket/tvtest"
+	"github.com/stretchr/testify/require"
+)
+
+func bar(t int64, c float64) TOHLCV {
+	return TOHLCV{Time: t, Open: c, High: c, Low: c, Close: c, Volume: 1}
+}
+
+func TestBarStore_Apply(t *testing.T) {
+	bs := barStore{window: 3}
+
+	// the history: every bar is final but the newest
+	events := bs.apply([]int{0, 1, 2}, []TOHLCV{bar(100, 1), bar(200, 2), bar(300, 3)})
+	require.Equal(t, []BarEvent{
+		{Kind: BarClosed, Index: 0, Bar: bar(100, 1)},
+		{Kind: BarClosed, Index: 1, Bar: bar(200, 2)},
+		{Kind: BarOpened, Index: 2, Bar: bar(300, 3)},
+	}, events)
+
+	// the forming bar changes, then an identical update is ignored
+	require.Equal(t, []BarEvent{{Kind: BarUpdated, Index: 2, Bar: bar(300, 3.5)}}, bs.apply([]int{2}, []TOHLCV{bar(300, 3.5)}))
+	require.Empty(t, bs.apply([]int{2}, []TOHLCV{bar(300, 3.5)}))
+
+	// a new bar closes the forming one
+	require.Equal(t, []BarEvent{
+		{Kind: BarClosed, Index: 2, Bar: bar(300, 3.5)},
+		{Kind: BarOpened, Index: 3, Bar: bar(400, 4)},
+	}, bs.apply([]int{2, 3}, []TOHLCV{bar(300, 3.5), bar(400, 4)}))
+
+	// the window keeps the last 3 bars
+	require.Equal(t, []indexedBar{{1, bar(200, 2)}, {2, bar(300, 3.5)}, {3, bar(400, 4)}}, bs.bars)
+
+	// bars without index are ignored
+	require.Empty(t, bs.apply([]int{noBarIndex}, []TOHLCV{bar(500, 5)}))
+	require.Len(t, bs.bars, 3)
+}
+
+func TestSocket_BarEvents(t *testing.T) {
+	server := tvtest.NewServer(t)
+	server.SetBars("NASDAQ:MSFT",
+		tvtest.Bar{Time: 1716290100, Open: 1, High: 2, Low: 0.5, Close: 1.5, Volume: 100},
+		tvtest.Bar{Time: 1716290400, Open: 1.5, High: 3, Low: 1, Close: 2.5, Volume: 200},
+	)
+	received := make(chan BarEvent, 10)
+	tv, err := ConnectWithOptions(context.Background(),
+		WithURL(server.URL()),
+		WithBarWindow(2),
+		WithOnBarEvent(func(series *Series, event BarEvent) {
+			require.Equal(t, "sds_1", series.ID())
+			received <- event
+		}),
+	)
+	require.NoError(t, err)
+	defer tv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	series, err := tv.CreateSeriesContext(ctx, "NASDAQ:MSFT", 10, "5", nil)
+	require.NoError(t, err)
+	next := func() BarEvent {
+		select {
+		case event := <-received:
+			require.Equal(t, "NASDAQ:MSFT", event.Symbol)
+			require.Equal(t, "5", event.Interval)
+			return event
+		case <-ctx.Done():
+			t.Fatal("missing bar event")
+			return BarEvent{}
+		}
+	}
+	require.Equal(t, BarClosed, next().Kind)
+	event := next()
+	require.Equal(t, BarOpened, event.Kind)
+	require.Equal(t, 1, event.Index)
+
+	server.Send(`{"m":"du","p":["cs_x",{"sds_1":{"s":[{"i":1,"v":[1716290400.0,1.5,3.5,1,3,250]}]}}]}`)
+	event = next()
+	require.Equal(t, BarUpdated, event.Kind)
+	require.Equal(t, 3.0, event.Bar.Close)
+
+	server.Send(`{"m":"du","p":["cs_x",{"sds_1":{"s":[{"i":2,"v":[1716290700.0,3,3,3,3,10]}]}}]}`)
+	event = next()
+	require.Equal(t, BarClosed, event.Kind)
+	require.Equal(t, 1, event.Index)
+	require.Equal(t, int64(250), event.Bar.Volume)
+	event = next()
+	require.Equal(t, BarOpened, event.Kind)
+	require.Equal(t, 2, event.Index)
+
+	last, ok := series.LastIndex()
+	require.True(t, ok)
+	require.Equal(t, 2, last)
+	require.Len(t, series.Bars(), 2)
+	_, ok = series.Bar(0)
+	require.False(t, ok)
+	b, ok := series.Bar(1)
+	require.True(t, ok)
+	require.Equal(t, 3.5, b.High)
+}
+
+func TestSocket_StreamBarEvents(t *testing.T) {
+	server := tvtest.NewServer(t)
+	server.SetBars("NASDAQ:MSFT", tvtest.Bar{Time: 1716290100, Open: 1, High: 2, Low: 0.5, Close: 1.5, Volume: 100})
+	tv, err := ConnectWithOptions(context.Background(), WithURL(server.URL()),
+		func(s *Socket) {
+			s.OnReceiveQuoteCallback = func(symbol string, hloc []TOHLCV) {
+				t.Errorf("unexpected bars %v", hloc)
+			}
+		},
+	)
+	require.NoError(t, err)
+	defer tv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := tv.StreamBarEvents(ctx, "NASDAQ:MSFT", "5")
+	require.NoError(t, err)
+	select {
+	case event := <-events:
+		require.Equal(t, BarOpened, event.Kind)
+		require.Equal(t, 1.5, event.Bar.Close)
+	case <-time.After(5 * time.Second):
+		t.Fatal("missing bar event")
+	}
+	cancel()
+	server.WaitFor(t, "remove_series")
+}
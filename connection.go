@@ -0,0 +1,95 @@
+package tvsocket
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// connection wraps a single websocket connection. Gorilla allows one concurrent writer only,
+// so every write goes through the queue drained by the writer goroutine.
+type connection struct {
+	conn      wsConn
+	recorder  *recorder
+	outbox    chan outboundMessage
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+type outboundMessage struct {
+	msgType int
+	data    []byte
+	errc    chan error
+}
+
+// wsConn is the part of *websocket.Conn used by the socket, ReplaySocket provides its own
+type wsConn interface {
+	ReadMessage() (messageType int, p []byte, err error)
+	WriteMessage(messageType int, data []byte) error
+	WriteControl(messageType int, data []byte, deadline time.Time) error
+	Close() error
+}
+
+func newConnection(conn wsConn, recorder *recorder) *connection {
+	c := &connection{
+		conn:     conn,
+		recorder: recorder,
+		outbox:   make(chan outboundMessage, 64),
+		closed:   make(chan struct{}),
+	}
+	go c.writeLoop()
+	return c
+}
+
+func (c *connection) writeLoop() {
+	for {
+		select {
+		case m := <-c.outbox:
+			err := c.conn.WriteMessage(m.msgType, m.data)
+			if err == nil && m.msgType == websocket.TextMessage {
+				c.recorder.record(Outbound, m.data)
+			}
+			m.errc <- err
+			if err != nil {
+				_ = c.close()
+				return
+			}
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+// read returns the next message of the connection, the single reader calls it
+func (c *connection) read() (msgType int, data []byte, err error) {
+	msgType, data, err = c.conn.ReadMessage()
+	if err == nil && msgType == websocket.TextMessage {
+		c.recorder.record(Inbound, data)
+	}
+	return
+}
+
+// write queues the message and waits until the writer goroutine has sent it
+func (c *connection) write(msgType int, data []byte) error {
+	errc := make(chan error, 1)
+	select {
+	case c.outbox <- outboundMessage{msgType: msgType, data: data, errc: errc}:
+	case <-c.closed:
+		return ErrConnectionClosed
+	}
+	select {
+	case err := <-errc:
+		return err
+	case <-c.closed:
+		return ErrConnectionClosed
+	}
+}
+
+func (c *connection) close() (err error) {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		err = c.conn.Close()
+	})
+	return
+}
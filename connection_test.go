@@ -0,0 +1,90 @@
+package tvsocket
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ivo100/tvsocket/tvtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSocket_ConcurrentWritersAndKeepAlive(t *testing.T) {
+	server := tvtest.NewServer(t)
+	tv, err := ConnectWithOptions(context.Background(), WithURL(server.URL()))
+	require.NoError(t, err)
+	defer tv.Close()
+
+	const writers, symbols = 10, 20
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < symbols; j++ {
+				assert.NoError(t, tv.AddSymbol(fmt.Sprintf("NASDAQ:S%d_%d", i, j)))
+			}
+		}(i)
+	}
+	for i := 1; i <= 20; i++ {
+		server.Send(fmt.Sprintf("~h~%d", i))
+	}
+	wg.Wait()
+
+	for i := 0; i < writers*symbols; i++ {
+		server.WaitFor(t, "quote_add_symbols")
+	}
+	for i := 1; i <= 20; i++ {
+		select {
+		case h := <-server.Heartbeats():
+			require.Equal(t, fmt.Sprintf("~h~%d", i), h)
+		case <-time.After(5 * time.Second):
+			t.Fatal("keep-alive not echoed")
+		}
+	}
+	require.Len(t, tv.symbols, writers*symbols)
+}
+
+func TestSocket_DispatchInOrder(t *testing.T) {
+	server := tvtest.NewServer(t)
+	tv, err := ConnectWithOptions(context.Background(), WithURL(server.URL()))
+	require.NoError(t, err)
+	defer tv.Close()
+
+	const updates = 200
+	var closes []float64
+	done := make(chan struct{})
+	_, err = tv.CreateSeries("NASDAQ:MSFT", 1, "5", func(symbol string, hloc []TOHLCV) {
+		closes = append(closes, hloc[0].Close)
+		if len(closes) == updates {
+			close(done)
+		}
+	})
+	require.NoError(t, err)
+	server.WaitFor(t, "create_series")
+
+	for i := 0; i < updates; i++ {
+		server.Send(fmt.Sprintf(`{"m":"du","p":["cs_x",{"sds_1":{"s":[{"i":0,"v":[1716290100.0,1,1,1,%d,1]}]}}]}`, i))
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("missing updates")
+	}
+	for i, v := range closes {
+		require.Equal(t, float64(i), v)
+	}
+}
+
+func TestConnection_WriteAfterClose(t *testing.T) {
+	server := tvtest.NewServer(t)
+	tv, err := ConnectWithOptions(context.Background(), WithURL(server.URL()))
+	require.NoError(t, err)
+
+	require.NoError(t, tv.Close())
+	require.ErrorIs(t, tv.connection().write(1, []byte("x")), ErrConnectionClosed)
+	require.NoError(t, tv.Close())
+}
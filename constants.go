@@ -42,19 +42,39 @@ const FinalPayloadCantBeParsedErrorContext = "The final JSON payload of the sock
 // FinalPayloadHasMissingPropertiesErrorContext ...
 const FinalPayloadHasMissingPropertiesErrorContext = "The final JSON payload doesn't have the expected data"
 
+// DispatchMessageErrorContext ...
+const DispatchMessageErrorContext = "Dispatching a received message to the callbacks"
+
 // ReadMessageErrorContext ...
 const ReadMessageErrorContext = "Error while reading new messages through the socket connection"
 
+// SeriesErrorContext ...
+const SeriesErrorContext = "Waiting for a chart series"
+
+// StudyErrorContext ...
+const StudyErrorContext = "Waiting for a study"
+
+// ResolveSymbolErrorContext ...
+const ResolveSymbolErrorContext = "Resolving a symbol"
+
+// ReconnectErrorContext ...
+const ReconnectErrorContext = "Reconnecting after the connection was lost"
+
+// AuthTokenErrorContext ...
+const AuthTokenErrorContext = "Getting the auth token"
+
+// Periods are the chart resolutions offered by TradingView, in the wire form returned by ParseResolution
 var Periods = []string{
 	"1",
 	"3",
 	"5",
 	"15",
+	"30",
 	"45",
-	"1h",
-	"2h",
-	"3h",
-	"4h",
+	"60",
+	"120",
+	"180",
+	"240",
 	"1D",
 	"1W",
 	"1M",
@@ -0,0 +1,119 @@
+package tvsocket
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ivo100/tvsocket/tvtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSocket_InitContextCancelsHandshake(t *testing.T) {
+	// accepts the websocket but never sends the session hello
+	upgrader := websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _, _ = conn.ReadMessage()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	s := &Socket{}
+	s.config.url = "ws" + strings.TrimPrefix(server.URL, "http")
+	err := s.InitContext(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestSocket_ContextOnlyBoundsTheDial(t *testing.T) {
+	server := tvtest.NewServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	tv, err := ConnectWithOptions(ctx, WithURL(server.URL()))
+	require.NoError(t, err)
+	defer tv.Close()
+
+	cancel()
+	require.NoError(t, tv.AddSymbol("NASDAQ:AAPL"))
+	m := server.WaitFor(t, "quote_add_symbols")
+	require.Equal(t, []any{tv.quoteSessionID, "NASDAQ:AAPL"}, m.Payload)
+	require.False(t, tv.closed())
+
+	require.NoError(t, tv.Close())
+	select {
+	case <-tv.loopDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the read loop is still running")
+	}
+}
+
+func TestSocket_RequestQuotesContext(t *testing.T) {
+	server := tvtest.NewServer(t)
+	tv, err := ConnectWithOptions(context.Background(), WithURL(server.URL()))
+	require.NoError(t, err)
+	defer tv.Close()
+
+	// nothing answers the request
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = tv.RequestQuotesContext(ctx, "NASDAQ:MSFT", 2, "5", nil)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	server.WaitFor(t, "remove_series")
+
+	go func() {
+		server.WaitFor(t, "create_series")
+		server.Send(
+			`{"m":"timescale_update","p":["cs_x",{"sds_2":{"s":[{"i":0,"v":[1716290100.0,426.65,426.83,426.65,426.8,458.0]}]}}]}`,
+			`{"m":"series_completed","p":["cs_x","sds_2","streaming","s1",{"rt_update_period":0}]}`,
+		)
+	}()
+	var bars []TOHLCV
+	err = tv.RequestQuotesContext(context.Background(), "NASDAQ:MSFT", 1, "5", func(symbol string, hloc []TOHLCV) {
+		bars = hloc
+	})
+	require.NoError(t, err)
+	require.Len(t, bars, 1)
+	require.Equal(t, 426.8, bars[0].Close)
+}
+
+func TestSocket_CloseContext(t *testing.T) {
+	server := tvtest.NewServer(t)
+	tv, err := ConnectWithOptions(context.Background(), WithURL(server.URL()))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, tv.CloseContext(ctx))
+	require.NoError(t, tv.Close())
+}
+
+func TestSocket_CloseContextFromCallback(t *testing.T) {
+	server := tvtest.NewServer(t)
+	var tv *Socket
+	closed := make(chan error, 1)
+	tv, err := ConnectWithOptions(context.Background(),
+		WithURL(server.URL()),
+		WithReconnect(&ReconnectPolicy{InitialBackoff: 10 * time.Millisecond}, func(attempt int) {
+			closed <- tv.CloseContext(context.Background())
+		}),
+	)
+	require.NoError(t, err)
+
+	server.Disconnect()
+	select {
+	case err = <-closed:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("CloseContext did not return in OnReconnectCallback")
+	}
+	require.True(t, tv.closed())
+}
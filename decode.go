@@ -0,0 +1,775 @@
+package tvsocket
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// The messages received the most, qsd and timescale_update/du, are decoded by hand into their structs
+// instead of going through map[string]any and mapstructure. jsonReader is the minimal scanner they share.
+
+var errUnexpectedEnd = errors.New("unexpected end of JSON input")
+
+type jsonReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *jsonReader) syntaxError(expected string) error {
+	if r.pos >= len(r.data) {
+		return errUnexpectedEnd
+	}
+	return errors.New("invalid character " + strconv.QuoteRune(rune(r.data[r.pos])) + " at offset " + strconv.Itoa(r.pos) + ", expected " + expected)
+}
+
+func (r *jsonReader) ws() {
+	for r.pos < len(r.data) {
+		switch r.data[r.pos] {
+		case ' ', '\t', '\n', '\r':
+			r.pos++
+		default:
+			return
+		}
+	}
+}
+
+// peek returns the next non-space byte, 0 at the end of the input
+func (r *jsonReader) peek() byte {
+	r.ws()
+	if r.pos >= len(r.data) {
+		return 0
+	}
+	return r.data[r.pos]
+}
+
+func (r *jsonReader) expect(c byte) error {
+	if r.peek() != c {
+		return r.syntaxError(strconv.QuoteRune(rune(c)))
+	}
+	r.pos++
+	return nil
+}
+
+// null consumes a null literal, it reports whether there was one
+func (r *jsonReader) null() bool {
+	if r.peek() == 'n' && len(r.data)-r.pos >= 4 && string(r.data[r.pos:r.pos+4]) == "null" {
+		r.pos += 4
+		return true
+	}
+	return false
+}
+
+func (r *jsonReader) literal(lit string) bool {
+	if len(r.data)-r.pos >= len(lit) && string(r.data[r.pos:r.pos+len(lit)]) == lit {
+		r.pos += len(lit)
+		return true
+	}
+	return false
+}
+
+func (r *jsonReader) readBool() (bool, error) {
+	r.ws()
+	if r.literal("true") {
+		return true, nil
+	}
+	if r.literal("false") {
+		return false, nil
+	}
+	return false, r.syntaxError("a boolean")
+}
+
+func (r *jsonReader) readNumber() (float64, error) {
+	num, err := r.scanNumber()
+	if err != nil {
+		return 0, err
+	}
+	f, err := strconv.ParseFloat(string(num), 64)
+	if err != nil {
+		return 0, errors.New("invalid number " + strconv.Quote(string(num)))
+	}
+	return f, nil
+}
+
+// scanNumber consumes the characters of a number without converting it
+func (r *jsonReader) scanNumber() ([]byte, error) {
+	r.ws()
+	start := r.pos
+	if r.pos < len(r.data) && r.data[r.pos] == '-' {
+		r.pos++
+	}
+	digits := r.pos
+	for r.pos < len(r.data) {
+		c := r.data[r.pos]
+		if (c >= '0' && c <= '9') || c == '.' || c == 'e' || c == 'E' || c == '+' || c == '-' {
+			r.pos++
+			continue
+		}
+		break
+	}
+	if r.pos == digits || r.data[digits] < '0' || r.data[digits] > '9' {
+		r.pos = start
+		return nil, r.syntaxError("a number")
+	}
+	return r.data[start:r.pos], nil
+}
+
+func (r *jsonReader) readString() (string, error) {
+	if err := r.expect('"'); err != nil {
+		return "", err
+	}
+	start := r.pos
+	for r.pos < len(r.data) {
+		switch c := r.data[r.pos]; {
+		case c == '"':
+			s := string(r.data[start:r.pos])
+			r.pos++
+			return s, nil
+		case c == '\\':
+			return r.readEscapedString(start)
+		case c < 0x20:
+			return "", r.syntaxError("a string character")
+		default:
+			r.pos++
+		}
+	}
+	return "", errUnexpectedEnd
+}
+
+// readEscapedString finishes a string holding escape sequences, pos is on the first backslash
+func (r *jsonReader) readEscapedString(start int) (string, error) {
+	buf := append([]byte(nil), r.data[start:r.pos]...)
+	for r.pos < len(r.data) {
+		c := r.data[r.pos]
+		switch {
+		case c == '"':
+			r.pos++
+			return string(buf), nil
+		case c < 0x20:
+			return "", r.syntaxError("a string character")
+		case c != '\\':
+			buf = append(buf, c)
+			r.pos++
+			continue
+		}
+		r.pos++
+		if r.pos >= len(r.data) {
+			return "", errUnexpectedEnd
+		}
+		switch e := r.data[r.pos]; e {
+		case '"', '\\', '/':
+			buf = append(buf, e)
+		case 'b':
+			buf = append(buf, '\b')
+		case 'f':
+			buf = append(buf, '\f')
+		case 'n':
+			buf = append(buf, '\n')
+		case 'r':
+			buf = append(buf, '\r')
+		case 't':
+			buf = append(buf, '\t')
+		case 'u':
+			r1, ok := r.hex4(r.pos + 1)
+			if !ok {
+				return "", r.syntaxError("a \\u escape")
+			}
+			r.pos += 4
+			if utf16.IsSurrogate(r1) {
+				r2, ok := rune(-1), false
+				if len(r.data)-r.pos > 2 && r.data[r.pos+1] == '\\' && r.data[r.pos+2] == 'u' {
+					r2, ok = r.hex4(r.pos + 3)
+				}
+				if dec := utf16.DecodeRune(r1, r2); ok && dec != utf8.RuneError {
+					r.pos += 6
+					r1 = dec
+				} else {
+					r1 = utf8.RuneError
+				}
+			}
+			buf = utf8.AppendRune(buf, r1)
+		default:
+			return "", r.syntaxError("an escape character")
+		}
+		r.pos++
+	}
+	return "", errUnexpectedEnd
+}
+
+func (r *jsonReader) hex4(at int) (rune, bool) {
+	if len(r.data)-at < 4 {
+		return 0, false
+	}
+	v, err := strconv.ParseUint(string(r.data[at:at+4]), 16, 32)
+	return rune(v), err == nil
+}
+
+// object calls member for each key of an object, member must consume the value
+func (r *jsonReader) object(member func(key string) error) error {
+	if err := r.expect('{'); err != nil {
+		return err
+	}
+	if r.peek() == '}' {
+		r.pos++
+		return nil
+	}
+	for {
+		key, err := r.readString()
+		if err != nil {
+			return err
+		}
+		if err = r.expect(':'); err != nil {
+			return err
+		}
+		if err = member(key); err != nil {
+			return err
+		}
+		switch r.peek() {
+		case ',':
+			r.pos++
+		case '}':
+			r.pos++
+			return nil
+		default:
+			return r.syntaxError("',' or '}'")
+		}
+	}
+}
+
+// array calls element for each value of an array, element must consume the value
+func (r *jsonReader) array(element func(i int) error) error {
+	if err := r.expect('['); err != nil {
+		return err
+	}
+	if r.peek() == ']' {
+		r.pos++
+		return nil
+	}
+	for i := 0; ; i++ {
+		if err := element(i); err != nil {
+			return err
+		}
+		switch r.peek() {
+		case ',':
+			r.pos++
+		case ']':
+			r.pos++
+			return nil
+		default:
+			return r.syntaxError("',' or ']'")
+		}
+	}
+}
+
+// readAny decodes any value the way encoding/json does into an interface
+func (r *jsonReader) readAny() (v any, err error) {
+	switch c := r.peek(); {
+	case c == '{':
+		m := make(map[string]any)
+		err = r.object(func(key string) (err error) {
+			m[key], err = r.readAny()
+			return
+		})
+		return m, err
+	case c == '[':
+		a := make([]any, 0)
+		err = r.array(func(int) error {
+			v, err := r.readAny()
+			a = append(a, v)
+			return err
+		})
+		return a, err
+	case c == '"':
+		return r.readString()
+	case c == 't' || c == 'f':
+		return r.readBool()
+	case c == 'n':
+		if r.null() {
+			return nil, nil
+		}
+		return nil, r.syntaxError("null")
+	default:
+		return r.readNumber()
+	}
+}
+
+// skip consumes a value, returning its raw bytes
+func (r *jsonReader) skip() (raw []byte, err error) {
+	r.ws()
+	start := r.pos
+	switch c := r.peek(); {
+	case c == '{':
+		err = r.object(func(string) error {
+			_, err := r.skip()
+			return err
+		})
+	case c == '[':
+		err = r.array(func(int) error {
+			_, err := r.skip()
+			return err
+		})
+	case c == '"':
+		_, err = r.readString()
+	case c == 't' || c == 'f':
+		_, err = r.readBool()
+	case c == 'n':
+		if !r.null() {
+			err = r.syntaxError("null")
+		}
+	default:
+		_, err = r.scanNumber()
+	}
+	return r.data[start:r.pos], err
+}
+
+// end checks nothing but spaces follows the value
+func (r *jsonReader) end() error {
+	if r.peek() != 0 {
+		return r.syntaxError("the end of the input")
+	}
+	return nil
+}
+
+// decodeEnvelope returns the name and the raw payload of a {"m": ..., "p": ...} message.
+// The payload is nil when the message has none.
+func decodeEnvelope(data []byte) (name string, payload []byte, err error) {
+	r := &jsonReader{data: data}
+	err = r.object(func(key string) (err error) {
+		switch key {
+		case "m":
+			if r.null() {
+				return nil
+			}
+			name, err = r.readString()
+		case "p":
+			if r.null() {
+				payload = nil
+				return nil
+			}
+			payload, err = r.skip()
+		default:
+			_, err = r.skip()
+		}
+		return
+	})
+	if err == nil {
+		err = r.end()
+	}
+	return
+}
+
+// decodeQuoteMessage decodes the second element of a qsd payload: {"n": symbol, "s": status, "v": {fields}}
+func decodeQuoteMessage(r *jsonReader) (msg *QuoteMessage, err error) {
+	msg = &QuoteMessage{}
+	err = r.object(func(key string) (err error) {
+		switch key {
+		case "n":
+			msg.Symbol, err = readStringField(r, key)
+		case "s":
+			msg.Status, err = readStringField(r, key)
+		case "v":
+			if r.null() {
+				msg.Data = nil
+				return nil
+			}
+			msg.Data, err = decodeQuoteData(r)
+		default:
+			_, err = r.skip()
+		}
+		return
+	})
+	return
+}
+
+func readStringField(r *jsonReader, key string) (string, error) {
+	if r.null() {
+		return "", nil
+	}
+	if r.peek() != '"' {
+		return "", errors.New("'" + key + "' expected a string")
+	}
+	return r.readString()
+}
+
+func decodeQuoteData(r *jsonReader) (q *QuoteData, err error) {
+	q = &QuoteData{}
+	err = r.object(func(key string) error {
+		switch key {
+		case "lp":
+			return readFloatPtr(r, key, &q.Price)
+		case "prev_close_price":
+			return readFloatPtr(r, key, &q.PrevClosePrice)
+		case "regular_close_price":
+			return readFloatPtr(r, key, &q.RegularClosePrice)
+		case "regular_close_time":
+			return readIntPtr(r, key, &q.RegularCloseTime)
+		case "high_price":
+			return readFloatPtr(r, key, &q.HighPrice)
+		case "low_price":
+			return readFloatPtr(r, key, &q.LowPrice)
+		case "open_price":
+			return readFloatPtr(r, key, &q.OpenPrice)
+		case "open_time":
+			return readIntPtr(r, key, &q.OpenTime)
+		case "volume":
+			return readFloatPtr(r, key, &q.Volume)
+		case "bid":
+			return readFloatPtr(r, key, &q.Bid)
+		case "ask":
+			return readFloatPtr(r, key, &q.Ask)
+		case "ch":
+			return readFloatPtr(r, key, &q.Change)
+		case "lp_time":
+			return readIntPtr(r, key, &q.Time)
+		case "chp":
+			return readFloatPtr(r, key, &q.ChangePercent)
+		case "bid_size":
+			return readFloatPtr(r, key, &q.BidSize)
+		case "ask_size":
+			return readFloatPtr(r, key, &q.AskSize)
+		case "rtc":
+			return readFloatPtr(r, key, &q.ExtendedPrice)
+		case "rtc_time":
+			return readIntPtr(r, key, &q.ExtendedTime)
+		case "rch":
+			return readFloatPtr(r, key, &q.ExtendedChange)
+		case "rchp":
+			return readFloatPtr(r, key, &q.ExtendedChangePercent)
+		case "all_time_high":
+			return readFloatPtr(r, key, &q.AllTimeHigh)
+		case "all_time_low":
+			return readFloatPtr(r, key, &q.AllTimeLow)
+		case "description":
+			return readStringPtr(r, key, &q.Description)
+		case "short_name":
+			return readStringPtr(r, key, &q.ShortName)
+		case "pro_name":
+			return readStringPtr(r, key, &q.ProName)
+		case "original_name":
+			return readStringPtr(r, key, &q.OriginalName)
+		case "exchange":
+			return readStringPtr(r, key, &q.Exchange)
+		case "listed_exchange":
+			return readStringPtr(r, key, &q.ListedExchange)
+		case "type":
+			return readStringPtr(r, key, &q.Type)
+		case "typespecs":
+			return readStrings(r, key, &q.TypeSpecs)
+		case "currency_code":
+			return readStringPtr(r, key, &q.CurrencyCode)
+		case "timezone":
+			return readStringPtr(r, key, &q.Timezone)
+		case "current_session":
+			return readStringPtr(r, key, &q.CurrentSession)
+		case "update_mode":
+			return readStringPtr(r, key, &q.UpdateMode)
+		case "fractional":
+			if r.null() {
+				return nil
+			}
+			if c := r.peek(); c != 't' && c != 'f' {
+				return errors.New("'" + key + "' expected a boolean")
+			}
+			b, err := r.readBool()
+			q.Fractional = &b
+			return err
+		case "pricescale":
+			return readIntPtr(r, key, &q.PriceScale)
+		case "minmov":
+			return readIntPtr(r, key, &q.MinMov)
+		case "pointvalue":
+			return readFloatPtr(r, key, &q.PointValue)
+		case "market_cap_basic":
+			return readFloatPtr(r, key, &q.MarketCapBasic)
+		case "total_shares_outstanding":
+			return readFloatPtr(r, key, &q.TotalSharesOutstanding)
+		case "source2":
+			if r.null() {
+				return nil
+			}
+			if r.peek() != '{' {
+				return errors.New("'" + key + "' expected an object")
+			}
+			q.Source2 = &QuoteSource{}
+			return decodeQuoteSource(r, q.Source2)
+		}
+		v, err := r.readAny()
+		if err != nil {
+			return err
+		}
+		if q.Extra == nil {
+			q.Extra = make(map[string]any)
+		}
+		q.Extra[key] = v
+		return nil
+	})
+	return
+}
+
+func decodeQuoteSource(r *jsonReader, src *QuoteSource) error {
+	return r.object(func(key string) (err error) {
+		switch key {
+		case "id":
+			src.ID, err = readStringField(r, key)
+		case "name":
+			src.Name, err = readStringField(r, key)
+		case "description":
+			src.Description, err = readStringField(r, key)
+		case "country":
+			src.Country, err = readStringField(r, key)
+		case "exchange-type":
+			src.ExchangeType, err = readStringField(r, key)
+		case "url":
+			src.URL, err = readStringField(r, key)
+		default:
+			_, err = r.skip()
+		}
+		return
+	})
+}
+
+// isNumber reports whether the next value starts like a number
+func (r *jsonReader) isNumber() bool {
+	c := r.peek()
+	return c == '-' || (c >= '0' && c <= '9')
+}
+
+func readFloatPtr(r *jsonReader, key string, dst **float64) error {
+	if r.null() {
+		return nil
+	}
+	if !r.isNumber() {
+		return errors.New("'" + key + "' expected a number")
+	}
+	f, err := r.readNumber()
+	*dst = &f
+	return err
+}
+
+func readIntPtr(r *jsonReader, key string, dst **int64) error {
+	if r.null() {
+		return nil
+	}
+	if !r.isNumber() {
+		return errors.New("'" + key + "' expected a number")
+	}
+	f, err := r.readNumber()
+	i := int64(f)
+	*dst = &i
+	return err
+}
+
+func readStringPtr(r *jsonReader, key string, dst **string) error {
+	if r.null() {
+		return nil
+	}
+	s, err := readStringField(r, key)
+	*dst = &s
+	return err
+}
+
+func readStrings(r *jsonReader, key string, dst *[]string) error {
+	if r.null() {
+		return nil
+	}
+	if r.peek() != '[' {
+		return errors.New("'" + key + "' expected an array")
+	}
+	values := make([]string, 0)
+	err := r.array(func(int) error {
+		s, err := readStringField(r, key)
+		values = append(values, s)
+		return err
+	})
+	*dst = values
+	return err
+}
+
+// splitQSD returns the quote of a qsd payload: [session id, {"n": symbol, "s": status, "v": {fields}}],
+// false when the payload is not an array of two values
+func splitQSD(payload []byte) (quote []byte, ok bool) {
+	r := &jsonReader{data: payload}
+	n := 0
+	err := r.array(func(i int) (err error) {
+		n++
+		raw, err := r.skip()
+		if i == 1 {
+			quote = raw
+		}
+		return
+	})
+	return quote, err == nil && n == 2
+}
+
+// parseQuoteMessage decodes the quote of a qsd payload
+func parseQuoteMessage(quote []byte) (msg *QuoteMessage, err error) {
+	r := &jsonReader{data: quote}
+	if r.peek() != '{' {
+		return nil, errors.New("the quote is not an object")
+	}
+	if msg, err = decodeQuoteMessage(r); err != nil {
+		return nil, err
+	}
+	return msg, r.end()
+}
+
+// timescaleUpdate holds the series bars and the study rows of a timescale_update or du message, keyed by series or study id
+type timescaleUpdate struct {
+	// ids lists the series and study ids in the order of the message, the order they are dispatched in
+	ids    []string
+	series map[string][]TOHLCV
+	// indexes holds the index in the series of each bar
+	indexes map[string][]int
+	// turnarounds holds the "t" of the series that carry one, the request the bars answer
+	turnarounds map[string]string
+	studies     map[string][]StudyRow
+}
+
+// parseTimeScaleUpdate decodes the payload of a timescale_update or du message:
+// [session id, {series id: {"s": [{"i": index, "v": [time, open, high, low, close, volume]}], "t": turnaround},
+// study id: {"st": [{"i": index, "v": [time, plot values...]}]}}]
+func parseTimeScaleUpdate(payload []byte) (update *timescaleUpdate, err error) {
+	r := &jsonReader{data: payload}
+	err = r.array(func(i int) (err error) {
+		if i != 1 {
+			_, err = r.skip()
+			return
+		}
+		update = &timescaleUpdate{series: make(map[string][]TOHLCV), indexes: make(map[string][]int)}
+		return r.object(func(id string) error {
+			update.ids = append(update.ids, id)
+			return r.object(func(key string) error {
+				switch key {
+				case "s":
+					hloc, indexes, err := decodeBars(r)
+					if hloc != nil {
+						update.series[id] = hloc
+						update.indexes[id] = indexes
+					}
+					return err
+				case "st":
+					rows, err := decodeStudyRows(r)
+					if rows != nil {
+						if update.studies == nil {
+							update.studies = make(map[string][]StudyRow)
+						}
+						update.studies[id] = rows
+					}
+					return err
+				case "t":
+					if r.peek() == '"' {
+						t, err := r.readString()
+						if update.turnarounds == nil {
+							update.turnarounds = make(map[string]string)
+						}
+						update.turnarounds[id] = t
+						return err
+					}
+				}
+				_, err := r.skip()
+				return err
+			})
+		})
+	})
+	if err == nil && update == nil {
+		err = errors.New("the payload has no series")
+	}
+	return
+}
+
+// noBarIndex marks a bar received without its index
+const noBarIndex = math.MinInt
+
+func decodeBars(r *jsonReader) (hloc []TOHLCV, indexes []int, err error) {
+	if r.null() {
+		return nil, nil, nil
+	}
+	hloc = make([]TOHLCV, 0)
+	indexes = make([]int, 0)
+	err = r.array(func(int) error {
+		var h TOHLCV
+		index := noBarIndex
+		err := r.object(func(key string) error {
+			if key == "i" && r.isNumber() {
+				i, err := r.readNumber()
+				index = int(i)
+				return err
+			}
+			if key != "v" {
+				_, err := r.skip()
+				return err
+			}
+			return r.array(func(j int) error {
+				if r.null() {
+					return nil
+				}
+				val, err := r.readNumber()
+				switch j {
+				case 0:
+					h.Time = int64(val)
+				case 1:
+					h.Open = val
+				case 2:
+					h.High = val
+				case 3:
+					h.Low = val
+				case 4:
+					h.Close = val
+				case 5:
+					h.Volume = int64(val)
+				}
+				return err
+			})
+		})
+		hloc = append(hloc, h)
+		indexes = append(indexes, index)
+		return err
+	})
+	return
+}
+
+func decodeStudyRows(r *jsonReader) (rows []StudyRow, err error) {
+	if r.null() {
+		return nil, nil
+	}
+	rows = make([]StudyRow, 0)
+	err = r.array(func(int) error {
+		var row StudyRow
+		err := r.object(func(key string) (err error) {
+			switch key {
+			case "i":
+				var i float64
+				i, err = r.readNumber()
+				row.Index = int(i)
+			case "v":
+				row.Values = make([]float64, 0)
+				err = r.array(func(j int) error {
+					if r.null() {
+						if j > 0 {
+							row.Values = append(row.Values, math.NaN())
+						}
+						return nil
+					}
+					val, err := r.readNumber()
+					if j == 0 {
+						row.Time = int64(val)
+						return err
+					}
+					if val == studyNoValue {
+						val = math.NaN()
+					}
+					row.Values = append(row.Values, val)
+					return err
+				})
+			default:
+				_, err = r.skip()
+			}
+			return
+		})
+		rows = append(rows, row)
+		return err
+	})
+	return
+}
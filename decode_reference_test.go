@@ -0,0 +1,250 @@
+package tvsocket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ivo100/tvsocket/protocol"
+	"github.com/mitchellh/mapstructure"
+	"github.com/stretchr/testify/require"
+)
+
+// The decoders below are the generic map[string]any + mapstructure path the hand-written ones replaced.
+// They are kept as the reference the fast path must agree with.
+
+func referenceParseQSD(payload []byte) (msg *QuoteMessage, err error) {
+	var m *SocketMessage
+	if err = json.Unmarshal(payload, &m); err != nil {
+		return
+	}
+	p, ok := m.Payload.([]any)
+	if !ok || len(p) != 2 {
+		return nil, errors.New("expected array with len 2")
+	}
+	quote, ok := p[1].(map[string]any)
+	if !ok {
+		return nil, errors.New("the quote is not an object")
+	}
+	err = mapstructure.Decode(quote, &msg)
+	return
+}
+
+func referenceParseTimeScaleUpdate(payload []byte) (series map[string][]TOHLCV, err error) {
+	d := make(map[string]any)
+	err = json.Unmarshal(payload, &d)
+	if err != nil {
+		return
+	}
+	p := d["p"].([]any)
+	if len(p) < 2 {
+		err = fmt.Errorf("parsing error")
+		return
+	}
+	var amap map[string]any
+	if amap = p[1].(map[string]any); amap == nil {
+		err = fmt.Errorf("parsing error")
+		return
+	}
+
+	series = make(map[string][]TOHLCV)
+	for id, v := range amap {
+		a := v.(map[string]any)["s"]
+		if a == nil {
+			continue
+		}
+		hloc := make([]TOHLCV, 0)
+		for _, v := range a.([]any) {
+			bar := v.(map[string]any)
+			vals := (bar["v"]).([]any)
+			var h TOHLCV
+			for j, val := range vals {
+				switch j {
+				case 0:
+					h.Time = int64(val.(float64))
+				case 1:
+					h.Open = val.(float64)
+				case 2:
+					h.High = val.(float64)
+				case 3:
+					h.Low = val.(float64)
+				case 4:
+					h.Close = val.(float64)
+				case 5:
+					h.Volume = int64(val.(float64))
+				}
+			}
+			hloc = append(hloc, h)
+		}
+		series[id] = hloc
+	}
+	return series, nil
+}
+
+// fastParseQSD runs the hand-written qsd decoding the way parseJSON does
+func fastParseQSD(payload []byte) (*QuoteMessage, error) {
+	_, p, err := decodeEnvelope(payload)
+	if err != nil {
+		return nil, err
+	}
+	quote, ok := splitQSD(p)
+	if !ok {
+		return nil, errors.New("expected array with len 2")
+	}
+	return parseQuoteMessage(quote)
+}
+
+func fastParseTimeScaleUpdate(payload []byte) (map[string][]TOHLCV, error) {
+	_, p, err := decodeEnvelope(payload)
+	if err != nil {
+		return nil, err
+	}
+	update, err := parseTimeScaleUpdate(p)
+	if err != nil {
+		return nil, err
+	}
+	return update.series, nil
+}
+
+// recordedMessages returns the messages of the recorded frames with the given names
+func recordedMessages(tb testing.TB, names ...string) map[string][][]byte {
+	tb.Helper()
+	messages := make(map[string][][]byte)
+	for _, name := range recordedFrames {
+		msgs, err := protocol.Decode(loadFrame(tb, name))
+		require.NoError(tb, err)
+		for _, msg := range msgs {
+			if msg.IsHeartbeat() {
+				continue
+			}
+			m, _, err := decodeEnvelope(msg.Payload)
+			require.NoError(tb, err)
+			for _, n := range names {
+				if m == n {
+					messages[m] = append(messages[m], msg.Payload)
+				}
+			}
+		}
+	}
+	return messages
+}
+
+var syntheticQSD = []string{
+	`{"m":"qsd","p":["qs_1",{"n":"NASDAQ:MSFT","s":"ok","v":{"lp":420.5,"lp_time":1716290100,"volume":12345678,"ch":-1.25,"chp":-0.3}}]}`,
+	`{"m":"qsd","p":["qs_1",{"n":"NASDAQ:MSFT","s":"ok","v":{"lp":null,"bid":null,"description":null,"typespecs":null,"source2":null,"fractional":null}}]}`,
+	`{"m":"qsd","p":["qs_1",{"n":"NASDAQ:MSFT","s":"ok","v":{"rtc":421,"rtc_time":1716290400.9,"pricescale":100,"minmov":1,"fractional":true,"typespecs":[],"currency_code":"USD"}}]}`,
+	`{"m":"qsd","p":["qs_1",{"n":"NASDAQ:MSFT","s":"ok","v":{"variable_tick_size":"0.0001 1 0.01","is_tradable":true,"rates_mc":{"to_usd":1,"list":[1,"a",null,{"x":[]}]},"empty":null}}]}`,
+	`{"m":"qsd","p":["qs_1",{"n":"NASDAQ:MSFT","s":"ok","v":{"source2":{"id":"BATS","name":"Cboe One","exchange-type":"exchange","extra":1},"description":"Micro\"soft\\ é 😀 \/"}}]}`,
+	`{"m":"qsd","p":["qs_1",{"n":"NASDAQ:MSFT","s":"error","errmsg":"invalid symbol","v":{}}]}`,
+	`{"p":["qs_1",{"s":"ok","v":{"lp":1e2,"volume":-1.5E-3}}],"m":"qsd"}`,
+}
+
+var syntheticTimeScaleUpdates = []string{
+	`{"m":"timescale_update","p":["cs_1",{"sds_1":{"node":"x","s":[{"i":0,"v":[1716290100,1,2,0.5,1.5,100]},{"i":1,"v":[1716290400.0,1.5,3,1,2.5,200.7]}],"ns":{"d":"","indexes":[]},"t":"s1","lbs":{"bar_close_time":1716290700}}},{"index":1,"zoffset":0,"changes":[1],"marks":[],"index_diff":[]}]}`,
+	`{"m":"du","p":["cs_1",{"sds_1":{"s":[{"i":5,"v":[1716290100,1,2,0.5]}]},"st8":{"st":[{"i":5,"v":[1716290100,1e100]}]}}]}`,
+	`{"m":"du","p":["cs_1",{"sds_1":{"s":[]},"sds_2":{"lbs":{}}}]}`,
+}
+
+func TestDecode_QSDMatchesReference(t *testing.T) {
+	payloads := recordedMessages(t, "qsd")["qsd"]
+	require.NotEmpty(t, payloads)
+	for _, p := range syntheticQSD {
+		payloads = append(payloads, []byte(p))
+	}
+	for _, payload := range payloads {
+		want, err := referenceParseQSD(payload)
+		require.NoError(t, err, string(payload))
+		got, err := fastParseQSD(payload)
+		require.NoError(t, err, string(payload))
+		require.Equal(t, want, got, string(payload))
+	}
+}
+
+func TestDecode_TimeScaleUpdateMatchesReference(t *testing.T) {
+	messages := recordedMessages(t, "timescale_update", "du")
+	payloads := append(messages["timescale_update"], messages["du"]...)
+	require.NotEmpty(t, messages["timescale_update"])
+	require.NotEmpty(t, messages["du"])
+	for _, p := range syntheticTimeScaleUpdates {
+		payloads = append(payloads, []byte(p))
+	}
+	for _, payload := range payloads {
+		want, err := referenceParseTimeScaleUpdate(payload)
+		require.NoError(t, err, string(payload))
+		got, err := fastParseTimeScaleUpdate(payload)
+		require.NoError(t, err, string(payload))
+		require.Equal(t, want, got, string(payload))
+	}
+}
+
+func TestDecode_Malformed(t *testing.T) {
+	for _, payload := range []string{
+		``,
+		`null`,
+		`{"m":"qsd","p":["qs_1",{"n":"X","s":"ok","v":{"lp":"420"}}]}`,
+		`{"m":"qsd","p":["qs_1",{"n":"X","s":"ok","v":{"lp":1,}}]}`,
+		`{"m":"qsd","p":["qs_1",{"n":"X","s":"ok","v":{"description":"\x"}}]}`,
+		`{"m":"qsd","p":["qs_1",{"n":"X","s":"ok","v":{"typespecs":[1]}}]}`,
+		`{"m":"qsd","p":["qs_1","X"]}`,
+		`{"m":"qsd","p":["qs_1",{"n":"X"}`,
+		`{"m":"qsd","p":["qs_1",{"n":"X","s":"ok","v":{}}]} trailing`,
+	} {
+		_, err := fastParseQSD([]byte(payload))
+		require.Error(t, err, payload)
+	}
+	for _, payload := range []string{
+		`{"m":"du","p":["cs_1"]}`,
+		`{"m":"du","p":["cs_1",["sds_1"]]}`,
+		`{"m":"du","p":["cs_1",{"sds_1":{"s":[{"i":0,"v":[1,"2"]}]}}]}`,
+		`{"m":"du","p":["cs_1",{"sds_1":{"s":{"i":0}}}]}`,
+	} {
+		_, err := fastParseTimeScaleUpdate([]byte(payload))
+		require.Error(t, err, payload)
+	}
+}
+
+func BenchmarkDecode_QSD(b *testing.B) {
+	payloads := recordedMessages(b, "qsd")["qsd"]
+	benchmarkDecode(b, payloads, func(payload []byte) error {
+		_, err := referenceParseQSD(payload)
+		return err
+	}, func(payload []byte) error {
+		_, err := fastParseQSD(payload)
+		return err
+	})
+}
+
+func BenchmarkDecode_TimeScaleUpdate(b *testing.B) {
+	messages := recordedMessages(b, "timescale_update", "du")
+	benchmarkDecode(b, append(messages["timescale_update"], messages["du"]...), func(payload []byte) error {
+		_, err := referenceParseTimeScaleUpdate(payload)
+		return err
+	}, func(payload []byte) error {
+		_, err := fastParseTimeScaleUpdate(payload)
+		return err
+	})
+}
+
+func benchmarkDecode(b *testing.B, payloads [][]byte, reference, fast func([]byte) error) {
+	var size int64
+	for _, payload := range payloads {
+		size += int64(len(payload))
+	}
+	for _, bench := range []struct {
+		name   string
+		decode func([]byte) error
+	}{{"reference", reference}, {"fast", fast}} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(size)
+			for i := 0; i < b.N; i++ {
+				for _, payload := range payloads {
+					if err := bench.decode(payload); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
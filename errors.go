@@ -0,0 +1,182 @@
+package tvsocket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+var (
+	// ErrHandshake is returned when the connection can't be established or the session hello is not recognized
+	ErrHandshake = errors.New("tvsocket: handshake failed")
+	// ErrProtocol is returned when a message received from the server can't be decoded
+	ErrProtocol = errors.New("tvsocket: protocol error")
+	// ErrServerError matches the *ServerError reported for critical_error and error messages
+	ErrServerError = errors.New("tvsocket: server error")
+	// ErrSymbolNotFound matches the *SeriesError of a symbol rejected by the server
+	ErrSymbolNotFound = errors.New("tvsocket: symbol not found")
+	// ErrConnectionClosed is returned when the connection is lost or has been closed
+	ErrConnectionClosed = errors.New("tvsocket: connection closed")
+	// ErrPanic matches the *PanicError reported when a callback or the decoding of a message panics
+	ErrPanic = errors.New("tvsocket: panic")
+	// ErrInvalidResolution is returned when an interval can't be parsed as a Resolution, before anything is sent
+	ErrInvalidResolution = errors.New("tvsocket: invalid resolution")
+	// ErrAuth matches the errors of the TokenProvider and the server errors about the auth token
+	ErrAuth = errors.New("tvsocket: authentication failed")
+	// ErrPermissionDenied matches the *SeriesError and *ServerError of data the auth token is not entitled to
+	ErrPermissionDenied = errors.New("tvsocket: permission denied")
+	// ErrCalledFromCallback is returned by the methods waiting for a reply when they are called from a callback,
+	// where the reply could never be dispatched
+	ErrCalledFromCallback = errors.New("tvsocket: blocking call from a callback")
+)
+
+// Error is the error reported to OnErrorCallback and returned by the socket methods.
+// It matches its Kind, one of the Err* sentinels, and its cause with errors.Is and errors.As.
+type Error struct {
+	Kind error
+	// Context is one of the *ErrorContext constants
+	Context string
+	Err     error
+}
+
+func newError(kind error, context string, err error) *Error {
+	return &Error{Kind: kind, Context: context, Err: err}
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("%v (%s)", e.Kind, e.Context)
+	}
+	return fmt.Sprintf("%v (%s): %v", e.Kind, e.Context, e.Err)
+}
+
+// Unwrap returns the kind and the cause of the error
+func (e *Error) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// ServerError carries the payload of a critical_error, error or protocol_error message
+type ServerError struct {
+	// Type is the message name, e.g. critical_error
+	Type    string
+	Payload []any
+}
+
+func (e *ServerError) Error() string {
+	parts := make([]string, 0, len(e.Payload))
+	for _, v := range e.Payload {
+		parts = append(parts, fmt.Sprint(v))
+	}
+	return e.Type + ": " + strings.Join(parts, " ")
+}
+
+// Is matches ErrServerError, and ErrAuth or ErrPermissionDenied when the reason given by the server says so
+func (e *ServerError) Is(target error) bool {
+	switch target {
+	case ErrServerError:
+		return true
+	case ErrAuth:
+		return e.hasReason(isAuthError)
+	case ErrPermissionDenied:
+		return e.hasReason(isPermissionDenied)
+	}
+	return false
+}
+
+// hasReason reports whether the reason of the payload matches.
+// The reason is its first string after the session id, e.g. invalid_parameters in ["cs_x", "invalid_parameters", "create_series"].
+func (e *ServerError) hasReason(match func(reason string) bool) bool {
+	for _, v := range e.Payload {
+		reason, ok := v.(string)
+		if !ok || strings.HasPrefix(reason, "cs_") || strings.HasPrefix(reason, "qs_") {
+			continue
+		}
+		return match(reason)
+	}
+	return false
+}
+
+// authReasons are the phrases of the reasons about the auth token
+var authReasons = []string{"auth token", "auth_token", "invalid token", "invalid_token", "token expired", "token_expired"}
+
+// isAuthError reports whether the reason given by the server is about the auth token, e.g. "invalid auth token"
+func isAuthError(reason string) bool {
+	reason = strings.ToLower(reason)
+	for _, phrase := range authReasons {
+		if strings.Contains(reason, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+// SeriesError is returned when the server rejects a chart series, its symbol or a study
+type SeriesError struct {
+	// Type is the message name, symbol_error, series_error or study_error
+	Type    string
+	Symbol  string
+	Message string
+}
+
+func (e *SeriesError) Error() string {
+	return "series error for " + e.Symbol + ": " + e.Message
+}
+
+// Is matches ErrPermissionDenied when the server denies the access to the data, and ErrSymbolNotFound for the other
+// symbol_error messages
+func (e *SeriesError) Is(target error) bool {
+	switch target {
+	case ErrPermissionDenied:
+		return isPermissionDenied(e.Message)
+	case ErrSymbolNotFound:
+		return e.Type == "symbol_error" && !isPermissionDenied(e.Message)
+	}
+	return false
+}
+
+// isPermissionDenied reports whether the reason given by the server is a denied access, e.g. "permission denied"
+func isPermissionDenied(reason string) bool {
+	reason = strings.ToLower(reason)
+	return strings.Contains(reason, "permission denied") || strings.Contains(reason, "permission_denied")
+}
+
+// PanicError is reported when a callback or the decoding of a message panics.
+// The panic is recovered and the socket keeps dispatching the next messages.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Is matches ErrPanic
+func (e *PanicError) Is(target error) bool {
+	return target == ErrPanic
+}
+
+// IsRetryable reports whether the operation may succeed on a new connection.
+// Lost connections, failed handshakes and network errors are retryable;
+// cancellations, protocol errors, server errors, rejected auth tokens, denied permissions and unknown symbols are not.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, ErrProtocol) || errors.Is(err, ErrServerError) || errors.Is(err, ErrSymbolNotFound) {
+		return false
+	}
+	if errors.Is(err, ErrAuth) || errors.Is(err, ErrPermissionDenied) {
+		return false
+	}
+	if errors.Is(err, ErrConnectionClosed) || errors.Is(err, ErrHandshake) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
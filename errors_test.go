@@ -0,0 +1,215 @@
+package tvsocket
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ivo100/tvsocket/protocol"
+	"github.com/ivo100/tvsocket/tvtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSocket_ServerErrorIsReported(t *testing.T) {
+	server := tvtest.NewServer(t)
+	reported := make(chan error, 1)
+	tv, err := ConnectWithOptions(context.Background(),
+		WithURL(server.URL()),
+		WithOnError(func(err error, context string) {
+			reported <- err
+		}),
+	)
+	require.NoError(t, err)
+	defer tv.Close()
+
+	server.Send(`{"m":"critical_error","p":["cs_x","invalid_parameters","create_series"]}`)
+
+	select {
+	case err = <-reported:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the error was not reported")
+	}
+	require.ErrorIs(t, err, ErrServerError)
+	var serverErr *ServerError
+	require.ErrorAs(t, err, &serverErr)
+	require.Equal(t, "critical_error", serverErr.Type)
+	require.Equal(t, []any{"cs_x", "invalid_parameters", "create_series"}, serverErr.Payload)
+	require.False(t, IsRetryable(err))
+}
+
+func TestSocket_MalformedFrameIsReported(t *testing.T) {
+	server := tvtest.NewServer(t)
+	reported := make(chan error, 1)
+	tv, err := ConnectWithOptions(context.Background(),
+		WithURL(server.URL()),
+		WithOnError(func(err error, context string) {
+			reported <- err
+		}),
+	)
+	require.NoError(t, err)
+	defer tv.Close()
+
+	server.SendRaw([]byte(`~m~120~m~{"m":"qsd"`))
+
+	select {
+	case err = <-reported:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the error was not reported")
+	}
+	require.ErrorIs(t, err, ErrProtocol)
+	require.ErrorIs(t, err, protocol.ErrMalformedFrame)
+}
+
+func TestSocket_HandshakeError(t *testing.T) {
+	_, err := ConnectWithOptions(context.Background(), WithURL("ws://127.0.0.1:1"))
+	require.ErrorIs(t, err, ErrHandshake)
+	require.True(t, IsRetryable(err))
+
+	var socketErr *Error
+	require.ErrorAs(t, err, &socketErr)
+	require.Equal(t, InitErrorContext, socketErr.Context)
+}
+
+func TestSocket_ClosedByCallerIsNotReported(t *testing.T) {
+	server := tvtest.NewServer(t)
+	reported := make(chan error, 1)
+	tv, err := ConnectWithOptions(context.Background(),
+		WithURL(server.URL()),
+		WithOnError(func(err error, context string) {
+			reported <- err
+		}),
+	)
+	require.NoError(t, err)
+	require.NoError(t, tv.Close())
+
+	err = tv.AddSymbol("NASDAQ:MSFT")
+	require.ErrorIs(t, err, ErrConnectionClosed)
+	select {
+	case err = <-reported:
+		t.Fatalf("unexpected error %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	require.False(t, IsRetryable(nil))
+	require.True(t, IsRetryable(newError(ErrConnectionClosed, ReadMessageErrorContext, io.EOF)))
+	require.False(t, IsRetryable(newError(ErrHandshake, InitErrorContext, context.Canceled)))
+	require.False(t, IsRetryable(newError(ErrProtocol, DecodeMessageErrorContext, errors.New("bad json"))))
+	require.False(t, IsRetryable(&SeriesError{Type: "symbol_error"}))
+	require.False(t, IsRetryable(errors.New("unknown")))
+	require.ErrorIs(t, newError(ErrConnectionClosed, ReadMessageErrorContext, io.EOF), io.EOF)
+}
+
+func TestSocket_CallbackPanicIsRecovered(t *testing.T) {
+	server := tvtest.NewServer(t)
+	reported := make(chan error, 1)
+	received := make(chan string, 2)
+	tv, err := ConnectWithOptions(context.Background(),
+		WithURL(server.URL()),
+		WithOnError(func(err error, context string) {
+			reported <- err
+		}),
+		WithOnReceiveData(func(symbol string, data *QuoteData) {
+			if symbol == "NASDAQ:MSFT" {
+				panic("callback bug")
+			}
+			received <- symbol
+		}),
+	)
+	require.NoError(t, err)
+	defer tv.Close()
+
+	server.SendRaw(append(frame(qsd("NASDAQ:MSFT", 1)), frame(qsd("NASDAQ:AAPL", 2))...))
+
+	select {
+	case err = <-reported:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the panic was not reported")
+	}
+	require.ErrorIs(t, err, ErrPanic)
+	var panicErr *PanicError
+	require.ErrorAs(t, err, &panicErr)
+	require.Equal(t, "callback bug", panicErr.Value)
+	require.NotEmpty(t, panicErr.Stack)
+	require.False(t, IsRetryable(err))
+
+	// the rest of the frame and the next ones are still dispatched
+	server.Send(qsd("NASDAQ:NVDA", 3))
+	for _, symbol := range []string{"NASDAQ:AAPL", "NASDAQ:NVDA"} {
+		select {
+		case got := <-received:
+			require.Equal(t, symbol, got)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s was not received", symbol)
+		}
+	}
+}
+
+func TestParseJSON_UnexpectedShapes(t *testing.T) {
+	s := &Socket{}
+	for _, payload := range []string{
+		`null`,
+		`[]`,
+		`{"m":"timescale_update"}`,
+		`{"m":"timescale_update","p":{"sds_1":{}}}`,
+		`{"m":"timescale_update","p":["cs_x"]}`,
+		`{"m":"timescale_update","p":["cs_x","sds_1"]}`,
+		`{"m":"du","p":["cs_x",{"sds_1":[]}]}`,
+		`{"m":"du","p":["cs_x",{"sds_1":{"s":[1,2]}}]}`,
+		`{"m":"du","p":["cs_x",{"sds_1":{"s":[{"i":0,"v":{"time":1}}]}}]}`,
+		`{"m":"du","p":["cs_x",{"sds_1":{"s":[{"i":0,"v":[1,"open"]}]}}]}`,
+		`{"m":"qsd","p":"qs_x"}`,
+		`{"m":"qsd","p":["qs_x"]}`,
+		`{"m":"qsd","p":["qs_x",["NASDAQ:MSFT"]]}`,
+		`{"m":"qsd","p":["qs_x",{"n":1,"s":"ok","v":{}}]}`,
+		`{"m":"qsd","p":["qs_x",{"n":"NASDAQ:MSFT","s":"ok","v":[]}]}`,
+		`{"m":"symbol_resolved","p":["cs_x","ss_1",[]]}`,
+		`{"m":"series_completed","p":{}}`,
+		`{"m":"critical_error","p":"oops"}`,
+	} {
+		require.NotPanics(t, func() {
+			_, _, err := s.parseJSON([]byte(payload))
+			require.Error(t, err, payload)
+		}, payload)
+	}
+}
+
+func TestSocket_BlockingCallFromCallback(t *testing.T) {
+	server := tvtest.NewServer(t)
+	server.SetBars("NASDAQ:MSFT", tvtest.Bar{Time: 1716290100, Close: 1})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var tv *Socket
+	results := make(chan error, 2)
+	tv, err := ConnectWithOptions(context.Background(),
+		WithURL(server.URL()),
+		WithOnReceiveData(func(symbol string, data *QuoteData) {
+			_, err := tv.GetBars(ctx, "NASDAQ:MSFT", "5", 10)
+			results <- err
+			go func() {
+				_, err := tv.GetBars(ctx, "NASDAQ:MSFT", "5", 10)
+				results <- err
+			}()
+		}),
+	)
+	require.NoError(t, err)
+	defer tv.Close()
+
+	server.Send(qsd("NASDAQ:MSFT", 1))
+	for _, want := range []error{ErrCalledFromCallback, nil} {
+		select {
+		case err := <-results:
+			if want == nil {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, want)
+			}
+		case <-ctx.Done():
+			t.Fatal("GetBars did not return")
+		}
+	}
+}
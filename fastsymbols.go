@@ -0,0 +1,87 @@
+package tvsocket
+
+import "slices"
+
+// MarkFast asks for real-time updates of the symbols, the others of the quote session are updated at a slower pace.
+// The symbols must have been added to the quote session, e.g. with AddSymbol or Subscribe.
+// quote_fast_symbols is sent with the whole fast set, unless the session is hibernating: the set is then sent by Resume.
+func (s *Socket) MarkFast(symbols ...string) error {
+	s.mu.Lock()
+	for _, symbol := range symbols {
+		if !slices.Contains(s.fastSymbols, symbol) {
+			s.fastSymbols = append(s.fastSymbols, symbol)
+		}
+	}
+	s.mu.Unlock()
+	return s.sendFastSymbols()
+}
+
+// MarkSlow moves the symbols back to the slower updates, see MarkFast
+func (s *Socket) MarkSlow(symbols ...string) error {
+	s.mu.Lock()
+	for _, symbol := range symbols {
+		s.fastSymbols = removeSymbol(s.fastSymbols, symbol)
+	}
+	s.mu.Unlock()
+	return s.sendFastSymbols()
+}
+
+// FastSymbols returns the symbols marked fast, in the order they were marked
+func (s *Socket) FastSymbols() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.fastSymbols...)
+}
+
+// HibernateAll moves every symbol of the quote session to the slower updates with quote_hibernate_all.
+// The symbols stay subscribed and marked fast; Resume restores their real-time updates.
+func (s *Socket) HibernateAll() error {
+	s.mu.Lock()
+	s.hibernating = true
+	s.mu.Unlock()
+	return s.sendSocketMessage(getSocketMessage("quote_hibernate_all", []any{s.quoteSessionID}))
+}
+
+// Resume ends HibernateAll and sends the symbols marked fast again
+func (s *Socket) Resume() error {
+	s.mu.Lock()
+	s.hibernating = false
+	s.mu.Unlock()
+	return s.sendFastSymbols()
+}
+
+// sendFastSymbols sends the fast set with quote_fast_symbols, nothing while hibernating
+func (s *Socket) sendFastSymbols() error {
+	s.mu.Lock()
+	if s.hibernating {
+		s.mu.Unlock()
+		return nil
+	}
+	p := []any{s.quoteSessionID}
+	for _, symbol := range s.fastSymbols {
+		p = append(p, symbol)
+	}
+	s.mu.Unlock()
+	return s.sendSocketMessage(getSocketMessage("quote_fast_symbols", p))
+}
+
+// restoreFastSymbols replays the hibernation or the fast set on a fresh connection
+func (s *Socket) restoreFastSymbols() error {
+	s.mu.Lock()
+	hibernating, fast := s.hibernating, len(s.fastSymbols) > 0
+	s.mu.Unlock()
+	if hibernating {
+		return s.sendSocketMessage(getSocketMessage("quote_hibernate_all", []any{s.quoteSessionID}))
+	}
+	if fast {
+		return s.sendFastSymbols()
+	}
+	return nil
+}
+
+// removeSymbol returns the symbols without the given one
+func removeSymbol(symbols []string, symbol string) []string {
+	return slices.DeleteFunc(symbols, func(v string) bool {
+		return v == symbol
+	})
+}
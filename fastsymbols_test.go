@@ -0,0 +1,67 @@
+package tvsocket
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ivo100/tvsocket/tvtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSocket_FastSymbols(t *testing.T) {
+	server := tvtest.NewServer(t)
+	reconnected := make(chan int, 1)
+	tv, err := ConnectWithOptions(context.Background(),
+		WithURL(server.URL()),
+		WithReconnect(&ReconnectPolicy{InitialBackoff: 10 * time.Millisecond}, func(attempt int) {
+			reconnected <- attempt
+		}),
+	)
+	require.NoError(t, err)
+	defer tv.Close()
+
+	for _, symbol := range []string{"NASDAQ:AAPL", "NASDAQ:MSFT", "NASDAQ:TSLA"} {
+		require.NoError(t, tv.AddSymbol(symbol))
+	}
+	require.NoError(t, tv.MarkFast("NASDAQ:AAPL", "NASDAQ:MSFT", "NASDAQ:AAPL"))
+	m := server.WaitFor(t, "quote_fast_symbols")
+	require.Equal(t, []any{tv.quoteSessionID, "NASDAQ:AAPL", "NASDAQ:MSFT"}, m.Payload)
+
+	require.NoError(t, tv.MarkSlow("NASDAQ:AAPL"))
+	m = server.WaitFor(t, "quote_fast_symbols")
+	require.Equal(t, []any{tv.quoteSessionID, "NASDAQ:MSFT"}, m.Payload)
+
+	// the fast set is only sent again on Resume
+	require.NoError(t, tv.HibernateAll())
+	m = server.WaitFor(t, "quote_hibernate_all")
+	require.Equal(t, []any{tv.quoteSessionID}, m.Payload)
+	require.NoError(t, tv.MarkFast("NASDAQ:TSLA"))
+	require.NoError(t, tv.Resume())
+	m = server.WaitFor(t, "quote_fast_symbols")
+	require.Equal(t, []any{tv.quoteSessionID, "NASDAQ:MSFT", "NASDAQ:TSLA"}, m.Payload)
+
+	require.NoError(t, tv.RemoveSymbol("NASDAQ:MSFT"))
+	require.Equal(t, []string{"NASDAQ:TSLA"}, tv.FastSymbols())
+
+	server.Disconnect()
+	select {
+	case <-reconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("socket did not reconnect")
+	}
+	server.WaitFor(t, "quote_add_symbols")
+	m = server.WaitFor(t, "quote_fast_symbols")
+	require.Equal(t, []any{tv.quoteSessionID, "NASDAQ:TSLA"}, m.Payload)
+
+	require.NoError(t, tv.HibernateAll())
+	server.WaitFor(t, "quote_hibernate_all")
+	server.Disconnect()
+	select {
+	case <-reconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("socket did not reconnect")
+	}
+	server.WaitFor(t, "quote_add_symbols")
+	server.WaitFor(t, "quote_hibernate_all")
+}
@@ -0,0 +1,99 @@
+package tvsocket
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ivo100/tvsocket/protocol"
+)
+
+// notesSeeds returns the frames and the messages captured in notes.txt and testdata/: the frames as they were
+// received, each of their messages framed on its own, and the pretty-printed outgoing messages compacted and framed
+func notesSeeds(tb testing.TB) [][]byte {
+	tb.Helper()
+	notes, err := os.ReadFile("notes.txt")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	var frames [][]byte
+	var pretty strings.Builder
+	scanner := bufio.NewScanner(bytes.NewReader(notes))
+	scanner.Buffer(nil, len(notes))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "~m~"):
+			frames = append(frames, []byte(line))
+		case strings.HasPrefix(line, "{") && pretty.Len() == 0 && json.Valid([]byte(line)):
+			frames = append(frames, frame(line))
+		case strings.HasPrefix(line, "{") || pretty.Len() > 0:
+			pretty.WriteString(line)
+			var compact bytes.Buffer
+			if json.Compact(&compact, []byte(pretty.String())) == nil {
+				frames = append(frames, frame(compact.String()))
+				pretty.Reset()
+			}
+		}
+	}
+	for _, name := range recordedFrames {
+		frames = append(frames, loadFrame(tb, name))
+	}
+
+	seeds := frames
+	for _, f := range frames {
+		msgs, _ := protocol.Decode(f)
+		for _, msg := range msgs {
+			seeds = append(seeds, frame(string(msg.Payload)))
+		}
+	}
+	return seeds
+}
+
+func TestNotesSeeds(t *testing.T) {
+	seeds := notesSeeds(t)
+	names := make(map[string]bool)
+	for _, seed := range seeds {
+		msgs, err := protocol.Decode(seed)
+		if err != nil {
+			t.Fatalf("seed %.80q: %v", seed, err)
+		}
+		for _, msg := range msgs {
+			name, _, err := decodeEnvelope(msg.Payload)
+			if err != nil {
+				t.Fatalf("seed %.80q: %v", seed, err)
+			}
+			names[name] = true
+		}
+	}
+	for _, name := range []string{"resolve_symbol", "quote_fast_symbols", "create_pointset", "timescale_update", "du", "qsd", "series_completed"} {
+		if !names[name] {
+			t.Errorf("no %s seed", name)
+		}
+	}
+}
+
+// FuzzParsePacket checks no packet makes the decoding panic, with every callback set
+func FuzzParsePacket(f *testing.F) {
+	for _, seed := range notesSeeds(f) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, packet []byte) {
+		s := &Socket{
+			OnReceiveMarketDataCallback: func(symbol string, data *QuoteData) {},
+			OnReceiveSnapshotCallback:   func(snapshot QuoteSnapshot) {},
+			OnErrorCallback: func(err error, context string) {
+				if errors.Is(err, ErrPanic) {
+					var panicErr *PanicError
+					errors.As(err, &panicErr)
+					t.Fatalf("%v\n%s", err, panicErr.Stack)
+				}
+			},
+		}
+		s.parsePacket(packet)
+	})
+}
@@ -0,0 +1,29 @@
+package tvsocket
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+)
+
+// goroutineID returns the id of the calling goroutine, read from the "goroutine N [running]:" header of its stack
+func goroutineID() uint64 {
+	var buf [64]byte
+	b := bytes.TrimPrefix(buf[:runtime.Stack(buf[:], false)], []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i > 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
+
+// checkNotInLoop fails the blocking methods called from a callback.
+// The callbacks run on the dispatch goroutine, and OnErrorCallback and OnReconnectCallback may run on the connection
+// goroutine: waiting there for a reply would wait forever, since the reply is read and dispatched by those goroutines.
+func (s *Socket) checkNotInLoop() error {
+	id := goroutineID()
+	if id != 0 && (id == s.dispatchGoroutine.Load() || id == s.connectionGoroutine.Load()) {
+		return newError(ErrCalledFromCallback, DispatchMessageErrorContext, nil)
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package tvsocket
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Option configures a Socket created by ConnectWithOptions
+type Option func(*Socket)
+
+// config holds the connection settings applied by the options
+type config struct {
+	url              string
+	dialer           *websocket.Dialer
+	headers          http.Header
+	proxy            *url.URL
+	tlsConfig        *tls.Config
+	handshakeTimeout time.Duration
+	streamBuffer     int
+	overflowPolicy   OverflowPolicy
+	streamHistory    int
+	barWindow        int
+	tokenProvider    TokenProvider
+	recorder         *recorder
+	// dialFunc replaces the websocket dial, ReplaySocket sets it
+	dialFunc func(ctx context.Context) (wsConn, error)
+}
+
+// ConnectWithOptions - Connects and returns the trading view socket object configured by the given options.
+// ctx bounds the dial and the handshake only, once connected the socket lives until Close.
+func ConnectWithOptions(ctx context.Context, opts ...Option) (socket *Socket, err error) {
+	socket = &Socket{}
+	for _, opt := range opts {
+		opt(socket)
+	}
+
+	err = socket.init(ctx, socket.fields...)
+
+	return
+}
+
+// WithURL overrides TradingViewSocketURL
+func WithURL(u string) Option {
+	return func(s *Socket) {
+		s.config.url = u
+	}
+}
+
+// WithDialer sets the websocket dialer, the proxy, TLS and handshake timeout options are applied on top of it
+func WithDialer(dialer *websocket.Dialer) Option {
+	return func(s *Socket) {
+		s.config.dialer = dialer
+	}
+}
+
+// WithHeaders adds the given headers to the handshake request, replacing the default values of the same keys
+func WithHeaders(headers http.Header) Option {
+	return func(s *Socket) {
+		if s.config.headers == nil {
+			s.config.headers = http.Header{}
+		}
+		for k, v := range headers {
+			s.config.headers[http.CanonicalHeaderKey(k)] = v
+		}
+	}
+}
+
+// WithUserAgent sets the User-Agent header of the handshake request
+func WithUserAgent(userAgent string) Option {
+	return WithHeaders(http.Header{"User-Agent": {userAgent}})
+}
+
+// WithProxy routes the connection through the given HTTP proxy
+func WithProxy(proxy *url.URL) Option {
+	return func(s *Socket) {
+		s.config.proxy = proxy
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used for wss:// URLs
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(s *Socket) {
+		s.config.tlsConfig = tlsConfig
+	}
+}
+
+// WithHandshakeTimeout limits the duration of the websocket handshake
+func WithHandshakeTimeout(timeout time.Duration) Option {
+	return func(s *Socket) {
+		s.config.handshakeTimeout = timeout
+	}
+}
+
+// WithFields sets the extra quote fields requested with quote_set_fields
+func WithFields(fields ...string) Option {
+	return func(s *Socket) {
+		s.fields = fields
+	}
+}
+
+// WithOnReceiveData sets the market data callback
+func WithOnReceiveData(callback OnReceiveDataCallback) Option {
+	return func(s *Socket) {
+		s.OnReceiveMarketDataCallback = callback
+	}
+}
+
+// WithOnSnapshot sets the callback receiving the merged quote of a symbol after each qsd
+func WithOnSnapshot(callback OnReceiveSnapshotCallback) Option {
+	return func(s *Socket) {
+		s.OnReceiveSnapshotCallback = callback
+	}
+}
+
+// WithOnStudy sets the callback receiving the rows of the studies added by AddStudy
+func WithOnStudy(callback OnReceiveStudyCallback) Option {
+	return func(s *Socket) {
+		s.OnReceiveStudyCallback = callback
+	}
+}
+
+// WithOnBarEvent sets the callback told which bars of the series opened, changed and closed
+func WithOnBarEvent(callback OnBarEventCallback) Option {
+	return func(s *Socket) {
+		s.OnBarEventCallback = callback
+	}
+}
+
+// WithBarWindow sets how many bars each series keeps for Series.Bars, 1000 by default
+func WithBarWindow(bars int) Option {
+	return func(s *Socket) {
+		s.config.barWindow = bars
+	}
+}
+
+// WithAuthToken sends the auth token of a TradingView account with set_auth_token instead of the unauthorized one
+func WithAuthToken(token string) Option {
+	return WithTokenProvider(staticToken(token))
+}
+
+// WithTokenProvider gets the auth token from the provider on every connection and before the token expires
+func WithTokenProvider(provider TokenProvider) Option {
+	return func(s *Socket) {
+		s.config.tokenProvider = provider
+	}
+}
+
+// WithOnError sets the error callback
+func WithOnError(callback OnErrorCallback) Option {
+	return func(s *Socket) {
+		s.OnErrorCallback = callback
+	}
+}
+
+// WithReconnect enables the reconnect supervisor
+func WithReconnect(policy *ReconnectPolicy, onReconnect OnReconnectCallback) Option {
+	return func(s *Socket) {
+		s.Reconnect = policy
+		s.OnReconnectCallback = onReconnect
+	}
+}
+
+// WithStreamBuffer sets the buffer size of the channels returned by Subscribe and StreamBars
+func WithStreamBuffer(size int) Option {
+	return func(s *Socket) {
+		s.config.streamBuffer = size
+	}
+}
+
+// WithOverflowPolicy decides what happens when the buffer of a stream is full
+func WithOverflowPolicy(policy OverflowPolicy) Option {
+	return func(s *Socket) {
+		s.config.overflowPolicy = policy
+	}
+}
+
+// WithStreamHistory sets the number of bars requested by StreamBars
+func WithStreamHistory(bars int) Option {
+	return func(s *Socket) {
+		s.config.streamHistory = bars
+	}
+}
+
+// WithRecorder writes every frame sent or received, heartbeats included, to w as JSON lines with their timestamps.
+// The recording can be played back with ReplaySocket. Write errors are ignored.
+func WithRecorder(w io.Writer) Option {
+	return func(s *Socket) {
+		s.config.recorder = newRecorder(w)
+	}
+}
+
+func (c *config) socketURL() string {
+	if c.url == "" {
+		return TradingViewSocketURL
+	}
+	return c.url
+}
+
+func (c *config) newDialer() *websocket.Dialer {
+	dialer := &websocket.Dialer{}
+	if c.dialer != nil {
+		d := *c.dialer
+		dialer = &d
+	}
+	if c.proxy != nil {
+		dialer.Proxy = http.ProxyURL(c.proxy)
+	}
+	if c.tlsConfig != nil {
+		dialer.TLSClientConfig = c.tlsConfig
+	}
+	if c.handshakeTimeout > 0 {
+		dialer.HandshakeTimeout = c.handshakeTimeout
+	}
+	return dialer
+}
+
+func (c *config) dial(ctx context.Context) (wsConn, error) {
+	if c.dialFunc != nil {
+		return c.dialFunc(ctx)
+	}
+	conn, _, err := c.newDialer().DialContext(ctx, c.socketURL(), c.requestHeaders())
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (c *config) requestHeaders() http.Header {
+	headers := getHeaders()
+	for k, v := range c.headers {
+		headers[k] = v
+	}
+	return headers
+}
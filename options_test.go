@@ -0,0 +1,58 @@
+package tvsocket
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ivo100/tvsocket/tvtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectWithOptions_URLAndHeaders(t *testing.T) {
+	server := tvtest.NewServer(t)
+
+	var dials atomic.Int32
+	dialer := &websocket.Dialer{
+		NetDialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dials.Add(1)
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}
+	tv, err := ConnectWithOptions(context.Background(),
+		WithURL(server.URL()),
+		WithDialer(dialer),
+		WithHandshakeTimeout(time.Second),
+		WithUserAgent("tvsocket-test"),
+		WithHeaders(http.Header{"x-custom": {"42"}}),
+		WithFields("volume", "bid"),
+	)
+	require.NoError(t, err)
+	defer tv.Close()
+
+	headers := <-server.Headers()
+	require.Equal(t, "tvsocket-test", headers.Get("User-Agent"))
+	require.Equal(t, "42", headers.Get("X-Custom"))
+	require.Equal(t, "https://www.tradingview.com", headers.Get("Origin"))
+	require.Equal(t, int32(1), dials.Load())
+
+	m := server.WaitFor(t, "quote_set_fields")
+	require.Contains(t, m.Payload, "volume")
+	require.Contains(t, m.Payload, "bid")
+}
+
+func TestConnectWithOptions_DialError(t *testing.T) {
+	var reported error
+	_, err := ConnectWithOptions(context.Background(),
+		WithURL("ws://127.0.0.1:1"),
+		WithOnError(func(err error, context string) {
+			reported = err
+		}),
+	)
+	require.Error(t, err)
+	require.Equal(t, err, reported)
+}
@@ -0,0 +1,56 @@
+package tvsocket
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+// recorded frames extracted from notes.txt
+var recordedFrames = []string{"timescale_update", "du_qsd", "qsd"}
+
+func loadFrame(tb testing.TB, name string) []byte {
+	tb.Helper()
+	frame, err := os.ReadFile("testdata/" + name + ".txt")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return frame
+}
+
+// busyFrame holds updates of many symbols, several of them updated more than once
+func busyFrame(symbols int, updates int) []byte {
+	var packet []byte
+	for i := 0; i < updates; i++ {
+		packet = append(packet, frame(fmt.Sprintf(`{"m":"qsd","p":["qs_x",{"n":"NASDAQ:S%d","s":"ok","v":{"lp":%d,"volume":%d}}]}`, i%symbols, i, i*10))...)
+	}
+	return packet
+}
+
+func benchmarkParsePacket(b *testing.B, packet []byte) {
+	s := &Socket{OnReceiveMarketDataCallback: func(symbol string, data *QuoteData) {}}
+	b.ReportAllocs()
+	b.SetBytes(int64(len(packet)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.parsePacket(packet)
+	}
+}
+
+func BenchmarkParsePacket_Recorded(b *testing.B) {
+	for _, name := range recordedFrames {
+		packet := loadFrame(b, name)
+		b.Run(name, func(b *testing.B) {
+			benchmarkParsePacket(b, packet)
+		})
+	}
+}
+
+func BenchmarkParsePacket_BusyFrame(b *testing.B) {
+	for _, size := range []struct{ symbols, updates int }{{10, 20}, {100, 200}, {300, 600}} {
+		packet := busyFrame(size.symbols, size.updates)
+		b.Run(fmt.Sprintf("%d_symbols_%d_updates", size.symbols, size.updates), func(b *testing.B) {
+			benchmarkParsePacket(b, packet)
+		})
+	}
+}
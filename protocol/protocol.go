@@ -0,0 +1,112 @@
+// Package protocol implements the ~m~ framing used by the TradingView socket.
+//
+// Every websocket frame holds one or more messages, each one prefixed by its length:
+//
+//	~m~41~m~{"m":"quote_create_session","p":["qs_x"]}
+//
+// Heartbeats are framed the same way, their payload starts with ~h~.
+package protocol
+
+import (
+	"errors"
+	"strconv"
+)
+
+const (
+	separator       = "~m~"
+	heartbeatPrefix = "~h~"
+	// maxLengthDigits bounds the length prefix so it can't overflow an int
+	maxLengthDigits = 10
+)
+
+// ErrMalformedFrame matches every *FrameError
+var ErrMalformedFrame = errors.New("protocol: malformed frame")
+
+// FrameError describes why a frame couldn't be decoded
+type FrameError struct {
+	// Offset is the position in the frame where decoding failed
+	Offset int
+	Reason string
+}
+
+func (e *FrameError) Error() string {
+	return "protocol: malformed frame at offset " + strconv.Itoa(e.Offset) + ": " + e.Reason
+}
+
+// Is matches ErrMalformedFrame
+func (e *FrameError) Is(target error) bool {
+	return target == ErrMalformedFrame
+}
+
+// Message is a single message of a frame
+type Message struct {
+	// Payload is the JSON message, or ~h~<n> for a heartbeat
+	Payload []byte
+}
+
+// IsHeartbeat ...
+func (m Message) IsHeartbeat() bool {
+	return len(m.Payload) >= len(heartbeatPrefix) && string(m.Payload[:len(heartbeatPrefix)]) == heartbeatPrefix
+}
+
+// Encode frames the message
+func Encode(msg Message) []byte {
+	length := strconv.Itoa(len(msg.Payload))
+	frame := make([]byte, 0, 2*len(separator)+len(length)+len(msg.Payload))
+	frame = append(frame, separator...)
+	frame = append(frame, length...)
+	frame = append(frame, separator...)
+	return append(frame, msg.Payload...)
+}
+
+// Decode splits a frame into its messages. The payloads share the memory of the frame.
+// On malformed input the messages decoded so far are returned along with a *FrameError.
+func Decode(frame []byte) (msgs []Message, err error) {
+	index := 0
+	for index < len(frame) {
+		var payload []byte
+		if payload, index, err = next(frame, index); err != nil {
+			return
+		}
+		msgs = append(msgs, Message{Payload: payload})
+	}
+	if len(msgs) == 0 {
+		err = &FrameError{Reason: "empty frame"}
+	}
+	return
+}
+
+// next decodes the message starting at index and returns the index of the following one
+func next(frame []byte, index int) (payload []byte, end int, err error) {
+	start := index
+	if !hasSeparator(frame, index) {
+		return nil, start, &FrameError{Offset: start, Reason: "missing ~m~ prefix"}
+	}
+	index += len(separator)
+
+	length, digits := 0, 0
+	for index < len(frame) && frame[index] >= '0' && frame[index] <= '9' {
+		if digits++; digits > maxLengthDigits {
+			return nil, start, &FrameError{Offset: index, Reason: "length too long"}
+		}
+		length = length*10 + int(frame[index]-'0')
+		index++
+	}
+	if digits == 0 {
+		return nil, start, &FrameError{Offset: index, Reason: "missing length"}
+	}
+	if !hasSeparator(frame, index) {
+		return nil, start, &FrameError{Offset: index, Reason: "missing ~m~ after the length"}
+	}
+	index += len(separator)
+
+	if length > len(frame)-index {
+		return nil, start, &FrameError{Offset: index, Reason: "truncated payload, expected " + strconv.Itoa(length) + " bytes"}
+	}
+	end = index + length
+	return frame[index:end:end], end, nil
+}
+
+func hasSeparator(frame []byte, index int) bool {
+	return len(frame)-index >= len(separator) && string(frame[index:index+len(separator)]) == separator
+}
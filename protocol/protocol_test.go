@@ -0,0 +1,99 @@
+package protocol
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncode(t *testing.T) {
+	require.Equal(t, `~m~9~m~{"m":"x"}`, string(Encode(Message{Payload: []byte(`{"m":"x"}`)})))
+	require.Equal(t, `~m~0~m~`, string(Encode(Message{})))
+}
+
+func TestDecode(t *testing.T) {
+	msgs, err := Decode([]byte(`~m~9~m~{"m":"a"}~m~4~m~~h~1~m~9~m~{"m":"b"}`))
+	require.NoError(t, err)
+	require.Len(t, msgs, 3)
+	require.Equal(t, `{"m":"a"}`, string(msgs[0].Payload))
+	require.False(t, msgs[0].IsHeartbeat())
+	require.Equal(t, `~h~1`, string(msgs[1].Payload))
+	require.True(t, msgs[1].IsHeartbeat())
+	require.Equal(t, `{"m":"b"}`, string(msgs[2].Payload))
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	for _, frame := range []string{
+		``,
+		`~`,
+		`~m~`,
+		`~m~~m~`,
+		`~m~12`,
+		`~m~12~m`,
+		`~m~12~m~{}`,
+		`~m~-1~m~`,
+		`~m~99999999999999999999~m~x`,
+		`{"m":"a"}`,
+		`~m~2~m~{}garbage`,
+	} {
+		_, err := Decode([]byte(frame))
+		require.ErrorIs(t, err, ErrMalformedFrame, frame)
+	}
+}
+
+func TestDecodeReturnsMessagesBeforeTheError(t *testing.T) {
+	msgs, err := Decode([]byte(`~m~2~m~{}~m~5~m~{}`))
+	require.ErrorIs(t, err, ErrMalformedFrame)
+	require.Len(t, msgs, 1)
+
+	var frameErr *FrameError
+	require.ErrorAs(t, err, &frameErr)
+	require.Equal(t, 16, frameErr.Offset)
+}
+
+func FuzzDecode(f *testing.F) {
+	f.Add([]byte(`~m~9~m~{"m":"a"}~m~4~m~~h~1`))
+	f.Add([]byte(`~m~52~m~{"session_id":"<0.1.2>","timestamp":1716413304}`))
+	f.Add([]byte(`~m~99~m~{"m":"qsd","p":["qs_9oiruOcoLaJc",{"n":"NASDAQ:MSFT","s":"ok","v":{"lp":426.8,"ch":1.2}}]}`))
+	f.Add([]byte(`~m~3~m~{}`))
+	f.Add([]byte(`~m~`))
+	f.Fuzz(func(t *testing.T, frame []byte) {
+		msgs, err := Decode(frame)
+		if err != nil {
+			return
+		}
+		// the messages of a valid frame survive a round trip
+		var encoded []byte
+		for _, msg := range msgs {
+			encoded = append(encoded, Encode(msg)...)
+		}
+		decoded, err := Decode(encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(decoded) != len(msgs) {
+			t.Fatalf("round trip mismatch: %q != %q", frame, encoded)
+		}
+		for i := range msgs {
+			if !bytes.Equal(msgs[i].Payload, decoded[i].Payload) {
+				t.Fatalf("round trip mismatch: %q != %q", frame, encoded)
+			}
+		}
+	})
+}
+
+func FuzzRoundTrip(f *testing.F) {
+	f.Add([]byte(`{"m":"a"}`))
+	f.Add([]byte(`~h~1`))
+	f.Add([]byte(`~m~`))
+	f.Fuzz(func(t *testing.T, payload []byte) {
+		msgs, err := Decode(Encode(Message{Payload: payload}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(msgs) != 1 || !bytes.Equal(msgs[0].Payload, payload) {
+			t.Fatalf("round trip mismatch: %q", payload)
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package tvsocket
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseJSON_QuoteFields(t *testing.T) {
+	s := &Socket{}
+	symbol, data, err := s.parseJSON([]byte(`{"m":"qsd","p":["qs_9oiruOcoLaJc",{"n":"NASDAQ:MSFT","s":"ok","v":{` +
+		`"typespecs":["common"],"fractional":false,` +
+		`"source2":{"country":"US","description":"Cboe One","exchange-type":"exchange","id":"BATS","name":"Cboe One","url":"markets.cboe.com/us/equities/overview/"},` +
+		`"pro_name":"NASDAQ:MSFT","currency_id":"USD","timezone":"America/New_York","minmov":1,"currency_code":"USD","pointvalue":1,` +
+		`"pricescale":100,"all_time_low":0.088543,"description":"Microsoft Corporation","short_name":"MSFT","type":"stock",` +
+		`"market_cap_basic":3199756508435,"total_shares_outstanding":7432306300.368264,"lp":430.52,"lp_time":1716413304,` +
+		`"ch":1.48,"chp":0.34,"bid":430.66,"ask":431.0,"bid_size":1.0,"ask_size":41.0,"logoid":"microsoft","is_tradable":true}}]}`))
+	require.NoError(t, err)
+	require.Equal(t, "NASDAQ:MSFT", symbol)
+
+	q := data.(*QuoteData)
+	require.Equal(t, 430.52, *q.Price)
+	require.Equal(t, int64(1716413304), *q.Time)
+	require.Equal(t, 0.34, *q.ChangePercent)
+	require.Equal(t, 41.0, *q.AskSize)
+	require.Equal(t, 0.088543, *q.AllTimeLow)
+	require.Equal(t, "Microsoft Corporation", *q.Description)
+	require.Equal(t, "MSFT", *q.ShortName)
+	require.Equal(t, "NASDAQ:MSFT", *q.ProName)
+	require.Equal(t, "stock", *q.Type)
+	require.Equal(t, "USD", *q.CurrencyCode)
+	require.Equal(t, []string{"common"}, q.TypeSpecs)
+	require.False(t, *q.Fractional)
+	require.Equal(t, int64(100), *q.PriceScale)
+	require.Equal(t, int64(1), *q.MinMov)
+	require.Equal(t, 1.0, *q.PointValue)
+	require.Equal(t, 3199756508435.0, *q.MarketCapBasic)
+	require.Equal(t, 7432306300.368264, *q.TotalSharesOutstanding)
+	require.Equal(t, &QuoteSource{
+		ID:           "BATS",
+		Name:         "Cboe One",
+		Description:  "Cboe One",
+		Country:      "US",
+		ExchangeType: "exchange",
+		URL:          "markets.cboe.com/us/equities/overview/",
+	}, q.Source2)
+	require.Nil(t, q.Volume)
+	require.Nil(t, q.ExtendedPrice)
+	require.Equal(t, map[string]any{
+		"currency_id": "USD",
+		"logoid":      "microsoft",
+		"is_tradable": true,
+	}, q.Extra)
+}
+
+func TestParseJSON_QuoteWithoutExtraFields(t *testing.T) {
+	s := &Socket{}
+	_, data, err := s.parseJSON([]byte(`{"m":"qsd","p":["qs_x",{"n":"NASDAQ:MSFT","s":"ok","v":{"lp":430.52}}]}`))
+	require.NoError(t, err)
+	require.Empty(t, data.(*QuoteData).Extra)
+}
+
+func TestParsePacket_CoalescesQuotesPerSymbol(t *testing.T) {
+	type update struct {
+		symbol string
+		data   *QuoteData
+	}
+	var updates []update
+	s := &Socket{OnReceiveMarketDataCallback: func(symbol string, data *QuoteData) {
+		updates = append(updates, update{symbol, data})
+	}}
+	var packet []byte
+	for _, payload := range []string{
+		`{"m":"qsd","p":["qs_x",{"n":"NASDAQ:MSFT","s":"ok","v":{"lp":420,"bid":419.9}}]}`,
+		`{"m":"qsd","p":["qs_x",{"n":"NASDAQ:AAPL","s":"ok","v":{"lp":190}}]}`,
+		`{"m":"qsd","p":["qs_x",{"n":"NASDAQ:MSFT","s":"ok","v":{"lp":420.5,"ask":420.6}}]}`,
+		`{"m":"qsd","p":["qs_x",{"n":"NASDAQ:NVDA","s":"ok","v":{"lp":190}}]}`,
+	} {
+		packet = append(packet, frame(payload)...)
+	}
+	s.parsePacket(packet)
+
+	require.Len(t, updates, 3)
+	require.Equal(t, "NASDAQ:MSFT", updates[0].symbol)
+	require.Equal(t, 420.5, *updates[0].data.Price)
+	require.Equal(t, 419.9, *updates[0].data.Bid)
+	require.Equal(t, 420.6, *updates[0].data.Ask)
+	// identical updates of different symbols are both delivered
+	require.Equal(t, "NASDAQ:AAPL", updates[1].symbol)
+	require.Equal(t, "NASDAQ:NVDA", updates[2].symbol)
+	require.Equal(t, updates[1].data, updates[2].data)
+}
@@ -0,0 +1,148 @@
+package tvsocket
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// ReconnectPolicy controls how the socket re-dials after the connection is lost.
+// Zero values fall back to the defaults of DefaultReconnectPolicy.
+type ReconnectPolicy struct {
+	// MaxAttempts is the number of consecutive failed attempts before giving up, 0 retries forever
+	MaxAttempts int
+	// InitialBackoff is the delay before the first attempt
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts
+	MaxBackoff time.Duration
+	// Multiplier grows the delay after every failed attempt
+	Multiplier float64
+	// Jitter randomizes every delay by +/- the given fraction (0..1)
+	Jitter float64
+}
+
+// DefaultReconnectPolicy ...
+func DefaultReconnectPolicy() *ReconnectPolicy {
+	return &ReconnectPolicy{
+		InitialBackoff: time.Second,
+		MaxBackoff:     30 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// backoff returns the delay before the given attempt, starting at 1
+func (p *ReconnectPolicy) backoff(attempt int) time.Duration {
+	initial, maxBackoff, multiplier := p.InitialBackoff, p.MaxBackoff, p.Multiplier
+	if initial <= 0 {
+		initial = time.Second
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = 30 * time.Second
+	}
+	if multiplier < 1 {
+		multiplier = 2
+	}
+
+	d := float64(initial) * math.Pow(multiplier, float64(attempt-1))
+	if d > float64(maxBackoff) {
+		d = float64(maxBackoff)
+	}
+	if p.Jitter > 0 {
+		d += d * p.Jitter * (rand.Float64()*2 - 1)
+	}
+	return time.Duration(d)
+}
+
+// reconnect re-dials the socket until it succeeds, the policy gives up or the socket is closed.
+// On success every tracked quote symbol and chart series is subscribed again.
+func (s *Socket) reconnect(cause error) error {
+	_ = s.connection().close()
+	s.dropOneShotSeries(newError(ErrConnectionClosed, ReadMessageErrorContext, cause))
+	s.dropResolvers(newError(ErrConnectionClosed, ReadMessageErrorContext, cause))
+
+	for attempt := 1; s.Reconnect.MaxAttempts == 0 || attempt <= s.Reconnect.MaxAttempts; attempt++ {
+		timer := time.NewTimer(s.Reconnect.backoff(attempt))
+		select {
+		case <-s.ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+
+		err := s.connect(s.ctx)
+		if err == nil {
+			err = s.restoreSubscriptions()
+		}
+		if err != nil {
+			cause = err
+			_ = s.connection().close()
+			continue
+		}
+
+		if s.OnReconnectCallback != nil {
+			s.OnReconnectCallback(attempt)
+		}
+		return nil
+	}
+
+	return newError(ErrConnectionClosed, ReconnectErrorContext, cause)
+}
+
+// restoreSubscriptions replays the quote symbols and their fast set, the chart series and their studies on a fresh connection
+func (s *Socket) restoreSubscriptions() (err error) {
+	s.mu.Lock()
+	symbols := append([]string(nil), s.symbols...)
+	s.mu.Unlock()
+
+	if len(symbols) > 0 {
+		p := []any{s.quoteSessionID}
+		for _, symbol := range symbols {
+			p = append(p, symbol)
+		}
+		if err = s.sendSocketMessage(getSocketMessage("quote_add_symbols", p)); err != nil {
+			return
+		}
+	}
+	if err = s.restoreFastSymbols(); err != nil {
+		return
+	}
+
+	for _, series := range s.activeSeries() {
+		// the history is received again, possibly with other indexes
+		s.mu.Lock()
+		series.store.reset()
+		s.mu.Unlock()
+		if err = s.sendSeries(series); err != nil {
+			return
+		}
+	}
+	for _, study := range s.activeStudies() {
+		if err = s.sendStudy(study); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (s *Socket) trackSymbol(symbol string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, v := range s.symbols {
+		if v == symbol {
+			return
+		}
+	}
+	s.symbols = append(s.symbols, symbol)
+}
+
+func (s *Socket) untrackSymbol(symbol string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, v := range s.symbols {
+		if v == symbol {
+			s.symbols = append(s.symbols[:i], s.symbols[i+1:]...)
+			return
+		}
+	}
+}
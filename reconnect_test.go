@@ -0,0 +1,143 @@
+package tvsocket
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ivo100/tvsocket/tvtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconnectPolicy_Backoff(t *testing.T) {
+	p := &ReconnectPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 2}
+	require.Equal(t, 100*time.Millisecond, p.backoff(1))
+	require.Equal(t, 200*time.Millisecond, p.backoff(2))
+	require.Equal(t, 800*time.Millisecond, p.backoff(4))
+	require.Equal(t, time.Second, p.backoff(10))
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := p.backoff(2)
+		require.GreaterOrEqual(t, d, 100*time.Millisecond)
+		require.LessOrEqual(t, d, 300*time.Millisecond)
+	}
+}
+
+func TestReconnectPolicy_Defaults(t *testing.T) {
+	p := &ReconnectPolicy{}
+	require.Equal(t, time.Second, p.backoff(1))
+	require.Equal(t, 30*time.Second, p.backoff(100))
+}
+
+func TestSocket_TrackSymbols(t *testing.T) {
+	s := &Socket{}
+	s.trackSymbol("NASDAQ:AAPL")
+	s.trackSymbol("NASDAQ:MSFT")
+	s.trackSymbol("NASDAQ:AAPL")
+	require.Equal(t, []string{"NASDAQ:AAPL", "NASDAQ:MSFT"}, s.symbols)
+	s.untrackSymbol("NASDAQ:AAPL")
+	require.Equal(t, []string{"NASDAQ:MSFT"}, s.symbols)
+}
+
+func TestSocket_ReconnectRestoresSubscriptions(t *testing.T) {
+	server := tvtest.NewServer(t)
+
+	reconnected := make(chan int, 1)
+	tv, err := ConnectWithOptions(context.Background(),
+		WithURL(server.URL()),
+		WithReconnect(&ReconnectPolicy{InitialBackoff: 10 * time.Millisecond}, func(attempt int) {
+			reconnected <- attempt
+		}),
+	)
+	require.NoError(t, err)
+	defer tv.Close()
+
+	require.NoError(t, tv.AddSymbol("NASDAQ:AAPL"))
+	require.NoError(t, tv.RequestQuotes("NASDAQ:MSFT", 10, "5", nil))
+	server.WaitFor(t, "create_series")
+
+	server.Disconnect()
+	select {
+	case attempt := <-reconnected:
+		require.Equal(t, 1, attempt)
+	case <-time.After(5 * time.Second):
+		t.Fatal("socket did not reconnect")
+	}
+
+	server.WaitFor(t, "chart_create_session")
+	m := server.WaitFor(t, "quote_add_symbols")
+	require.Equal(t, []any{tv.quoteSessionID, "NASDAQ:AAPL", "NASDAQ:MSFT"}, m.Payload)
+	m = server.WaitFor(t, "resolve_symbol")
+	require.Contains(t, m.Payload, `={"symbol":"NASDAQ:MSFT"}`)
+	server.WaitFor(t, "create_series")
+}
+
+func TestSocket_MessageErrorKeepsTheConnection(t *testing.T) {
+	server := tvtest.NewServer(t)
+
+	reported := make(chan error, 1)
+	reconnected := make(chan int, 1)
+	tv, err := ConnectWithOptions(context.Background(),
+		WithURL(server.URL()),
+		WithOnError(func(err error, context string) {
+			reported <- err
+		}),
+		WithReconnect(&ReconnectPolicy{InitialBackoff: 10 * time.Millisecond}, func(attempt int) {
+			reconnected <- attempt
+		}),
+	)
+	require.NoError(t, err)
+	defer tv.Close()
+	quotes, err := tv.Subscribe(context.Background(), "NASDAQ:MSFT")
+	require.NoError(t, err)
+
+	server.Send(`{"m":"qsd","p":["qs_x",{"n":"NASDAQ:NOPE","s":"error","v":{}}]}`)
+	select {
+	case err = <-reported:
+		require.ErrorIs(t, err, ErrProtocol)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the error was not reported")
+	}
+	server.Send(qsd("NASDAQ:MSFT", 420))
+	require.Equal(t, "NASDAQ:MSFT", (<-quotes).Symbol)
+	select {
+	case <-reconnected:
+		t.Fatal("the socket reconnected after an error of a single message")
+	case <-time.After(100 * time.Millisecond):
+	}
+	require.Equal(t, 1, server.Connections())
+}
+
+func TestSocket_ErrorOfAReplacedConnection(t *testing.T) {
+	server := tvtest.NewServer(t)
+
+	reconnected := make(chan int, 2)
+	tv, err := ConnectWithOptions(context.Background(),
+		WithURL(server.URL()),
+		WithOnError(func(err error, context string) {}),
+		WithReconnect(&ReconnectPolicy{InitialBackoff: 10 * time.Millisecond}, func(attempt int) {
+			reconnected <- attempt
+		}),
+	)
+	require.NoError(t, err)
+	defer tv.Close()
+
+	old := tv.connection()
+	server.Disconnect()
+	select {
+	case <-reconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("socket did not reconnect")
+	}
+
+	// a write failing late on the old connection leaves the new one open
+	tv.onConnectionError(old, newError(ErrConnectionClosed, SendMessageErrorContext, nil), SendMessageErrorContext)
+	require.NoError(t, tv.AddSymbol("NASDAQ:MSFT"))
+	server.WaitFor(t, "quote_add_symbols")
+	select {
+	case <-reconnected:
+		t.Fatal("the new connection was closed")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,60 @@
+package tvsocket
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Direction tells whether a recorded frame was received or sent
+type Direction string
+
+const (
+	Inbound  Direction = "in"
+	Outbound Direction = "out"
+)
+
+// RecordedFrame is a websocket frame written by WithRecorder, one JSON object per line
+type RecordedFrame struct {
+	Time      time.Time `json:"time"`
+	Direction Direction `json:"dir"`
+	Data      string    `json:"data"`
+}
+
+type recorder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func newRecorder(w io.Writer) *recorder {
+	return &recorder{enc: json.NewEncoder(w)}
+}
+
+// record is a no-op on a nil recorder
+func (r *recorder) record(direction Direction, data []byte) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_ = r.enc.Encode(RecordedFrame{Time: time.Now(), Direction: direction, Data: string(data)})
+}
+
+// ReadRecording decodes the frames written by WithRecorder
+func ReadRecording(r io.Reader) (frames []RecordedFrame, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var frame RecordedFrame
+		if err = json.Unmarshal(scanner.Bytes(), &frame); err != nil {
+			return nil, err
+		}
+		frames = append(frames, frame)
+	}
+	return frames, scanner.Err()
+}
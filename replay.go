@@ -0,0 +1,309 @@
+package tvsocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ivo100/tvsocket/protocol"
+)
+
+// ReplaySocket plays a recording made with WithRecorder back through the message parsing of a Socket,
+// so the same callbacks, series and streams receive the same data without a connection.
+//
+// Every frame sent by the recorded session is a sync point: the received frames that followed it
+// are held back until the ReplaySocket has sent as many frames. Series ids are allocated in order,
+// so repeating the recorded calls in the same order reproduces the session deterministically.
+// A replay whose calls differ from the recording stalls until it is closed.
+//
+// A passive replay, created by NewPassiveReplaySocket, ignores the sync points: it plays a capture made by code
+// that can't be run again, and registers the recorded chart series itself.
+type ReplaySocket struct {
+	*Socket
+	conn *replayConn
+
+	mu       sync.Mutex
+	expected int
+	parsed   int
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+var _ SocketInterface = (*ReplaySocket)(nil)
+
+// NewReplaySocket returns a socket replaying the frames once Init is called.
+// A speed of 1 keeps the recorded pace, 10 replays ten times faster and 0 replays as fast as possible.
+// The options configure the callbacks and streams like ConnectWithOptions, the connection options are ignored.
+func NewReplaySocket(frames []RecordedFrame, speed float64, opts ...Option) *ReplaySocket {
+	return newReplaySocket(frames, speed, false, opts)
+}
+
+// NewPassiveReplaySocket returns a socket replaying the received frames without waiting for the recorded calls.
+// The series of the recorded create_series and modify_series frames are registered under their recorded ids, so their
+// bars reach OnReceiveQuoteCallback, OnBarEventCallback and RecordedSeries.
+func NewPassiveReplaySocket(frames []RecordedFrame, speed float64, opts ...Option) *ReplaySocket {
+	r := newReplaySocket(frames, speed, true, opts)
+	r.registerRecordedSeries(frames)
+	return r
+}
+
+func newReplaySocket(frames []RecordedFrame, speed float64, passive bool, opts []Option) *ReplaySocket {
+	r := &ReplaySocket{
+		Socket: &Socket{},
+		conn:   newReplayConn(frames, speed),
+		done:   make(chan struct{}),
+	}
+	r.conn.passive = passive
+	for _, opt := range opts {
+		opt(r.Socket)
+	}
+	used := false
+	r.config.dialFunc = func(ctx context.Context) (wsConn, error) {
+		if used {
+			return nil, errors.New("the recording has already been replayed")
+		}
+		used = true
+		return r.conn, nil
+	}
+	r.dispatched = r.onDispatched
+
+	// the session hello is read by the handshake and heartbeats are answered by the read loop,
+	// every other received frame goes through parsePacket
+	for i, frame := range frames {
+		if frame.Direction == Inbound && i > 0 && !isKeepAliveMsg([]byte(frame.Data)) {
+			r.expected++
+		}
+	}
+	if r.expected == 0 {
+		r.finish()
+	}
+	return r
+}
+
+// RecordedSeries returns the series registered by a passive replay, in creation order
+func (r *ReplaySocket) RecordedSeries() []*Series {
+	return r.activeSeries()
+}
+
+// registerRecordedSeries replays the series requests of the recorded session on the socket state
+func (r *ReplaySocket) registerRecordedSeries(frames []RecordedFrame) {
+	s := r.Socket
+	symbols := make(map[string]string)
+	for _, frame := range frames {
+		if frame.Direction != Outbound {
+			continue
+		}
+		msgs, err := protocol.Decode([]byte(frame.Data))
+		if err != nil {
+			continue
+		}
+		for _, msg := range msgs {
+			var m SocketMessage
+			if json.Unmarshal(msg.Payload, &m) != nil {
+				continue
+			}
+			p, _ := m.Payload.([]any)
+			arg := func(i int) string {
+				if i < len(p) {
+					v, _ := p[i].(string)
+					return v
+				}
+				return ""
+			}
+			switch m.Message {
+			case "resolve_symbol":
+				symbols[arg(1)] = recordedSymbol(arg(2))
+			case "create_series":
+				bars := 0
+				if len(p) > 5 {
+					n, _ := p[5].(float64)
+					bars = int(n)
+				}
+				series := s.newSeries(symbols[arg(3)], bars, arg(4), nil)
+				s.mu.Lock()
+				delete(s.series, series.id)
+				series.id, series.symbolID = arg(1), arg(3)
+				series.turnaround = turnaroundNumber(arg(2))
+				s.series[series.id] = series
+				s.mu.Unlock()
+			case "modify_series":
+				s.mu.Lock()
+				if series, ok := s.series[arg(1)]; ok {
+					series.symbolID, series.symbol, series.interval = arg(3), symbols[arg(3)], arg(4)
+					series.turnaround = turnaroundNumber(arg(2))
+				}
+				s.mu.Unlock()
+			case "remove_series":
+				s.mu.Lock()
+				delete(s.series, arg(1))
+				s.mu.Unlock()
+			}
+		}
+	}
+}
+
+// recordedSymbol returns the symbol of a resolve_symbol argument, specs with options are kept whole
+func recordedSymbol(arg string) string {
+	var spec map[string]any
+	if !isSymbolSpec(arg) || json.Unmarshal([]byte(arg[1:]), &spec) != nil || len(spec) != 1 {
+		return arg
+	}
+	if symbol, ok := spec["symbol"].(string); ok {
+		return symbol
+	}
+	return arg
+}
+
+// turnaroundNumber parses a turnaround id such as s2, 1 when it can't be parsed
+func turnaroundNumber(id string) int {
+	n, err := strconv.Atoi(strings.TrimPrefix(id, "s"))
+	if err != nil {
+		return 1
+	}
+	return n
+}
+
+// Done is closed once every received frame of the recording has been parsed and dispatched
+func (r *ReplaySocket) Done() <-chan struct{} {
+	return r.done
+}
+
+func (r *ReplaySocket) onDispatched() {
+	r.mu.Lock()
+	r.parsed++
+	finished := r.parsed >= r.expected
+	r.mu.Unlock()
+	if finished {
+		r.finish()
+	}
+}
+
+func (r *ReplaySocket) finish() {
+	r.doneOnce.Do(func() {
+		close(r.done)
+	})
+}
+
+// replayConn returns the received frames of a recording and counts the frames written to it
+type replayConn struct {
+	frames []RecordedFrame
+	speed  float64
+	next   int
+	// recorded counts the frames sent by the recorded session so far, heartbeats excluded
+	recorded int
+	// passive replays don't wait for the recorded frames to be sent again
+	passive   bool
+	mu        sync.Mutex
+	sent      int
+	written   chan struct{}
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newReplayConn(frames []RecordedFrame, speed float64) *replayConn {
+	return &replayConn{
+		frames:  frames,
+		speed:   speed,
+		written: make(chan struct{}, 1),
+		closed:  make(chan struct{}),
+	}
+}
+
+// ReadMessage waits for the sync point, unless the replay is passive, and the recorded delay of the next received frame.
+// Once the recording is over it blocks until the connection is closed.
+func (c *replayConn) ReadMessage() (messageType int, p []byte, err error) {
+	for c.next < len(c.frames) {
+		frame := c.frames[c.next]
+		if c.next > 0 {
+			if err = c.sleep(frame.Time.Sub(c.frames[c.next-1].Time)); err != nil {
+				return
+			}
+		}
+		c.next++
+		if frame.Direction == Outbound {
+			if !isKeepAliveMsg([]byte(frame.Data)) {
+				c.recorded++
+			}
+			continue
+		}
+		if !c.passive {
+			if err = c.waitSent(c.recorded); err != nil {
+				return
+			}
+		}
+		return websocket.TextMessage, []byte(frame.Data), nil
+	}
+	<-c.closed
+	return 0, nil, ErrConnectionClosed
+}
+
+// waitSent blocks until n frames other than heartbeats have been written
+func (c *replayConn) waitSent(n int) error {
+	for {
+		c.mu.Lock()
+		sent := c.sent
+		c.mu.Unlock()
+		if sent >= n {
+			return nil
+		}
+		select {
+		case <-c.written:
+		case <-c.closed:
+			return ErrConnectionClosed
+		}
+	}
+}
+
+func (c *replayConn) sleep(d time.Duration) error {
+	if c.speed <= 0 || d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(time.Duration(float64(d) / c.speed))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-c.closed:
+		return ErrConnectionClosed
+	}
+}
+
+// WriteMessage discards the frame, counting it unless it is a heartbeat
+func (c *replayConn) WriteMessage(messageType int, data []byte) error {
+	select {
+	case <-c.closed:
+		return ErrConnectionClosed
+	default:
+	}
+	if isKeepAliveMsg(data) {
+		return nil
+	}
+	c.mu.Lock()
+	c.sent++
+	c.mu.Unlock()
+	select {
+	case c.written <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// WriteControl acknowledges a close frame right away
+func (c *replayConn) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	if messageType == websocket.CloseMessage {
+		return c.Close()
+	}
+	return nil
+}
+
+func (c *replayConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+	})
+	return nil
+}
@@ -0,0 +1,148 @@
+package tvsocket
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ivo100/tvsocket/protocol"
+	"github.com/ivo100/tvsocket/tvtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSocket_RecordAndReplay(t *testing.T) {
+	server := tvtest.NewServer(t)
+	server.SetBars("NASDAQ:MSFT",
+		tvtest.Bar{Time: 1716290100, Open: 1, High: 2, Low: 0.5, Close: 1.5, Volume: 100},
+		tvtest.Bar{Time: 1716290400, Open: 1.5, High: 3, Low: 1, Close: 2.5, Volume: 200},
+	)
+	server.SetQuote("NASDAQ:MSFT", map[string]any{"lp": 420.5})
+
+	var recording bytes.Buffer
+	session := func(tv *Socket) ([]TOHLCV, QuoteEvent) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		bars, err := tv.GetBars(ctx, "NASDAQ:MSFT", "5", 10)
+		require.NoError(t, err)
+		quotes, err := tv.Subscribe(ctx, "NASDAQ:MSFT")
+		require.NoError(t, err)
+		select {
+		case event := <-quotes:
+			return bars, event
+		case <-ctx.Done():
+			t.Fatal("the quote was not received")
+			return nil, QuoteEvent{}
+		}
+	}
+
+	tv, err := ConnectWithOptions(context.Background(), WithURL(server.URL()), WithRecorder(&recording))
+	require.NoError(t, err)
+	bars, quote := session(tv)
+	server.Heartbeat(1)
+	<-server.Heartbeats()
+	require.NoError(t, tv.Close())
+
+	frames, err := ReadRecording(&recording)
+	require.NoError(t, err)
+	require.Equal(t, Inbound, frames[0].Direction)
+	require.Contains(t, frames[0].Data, "session_id")
+	require.Equal(t, Outbound, frames[1].Direction)
+	require.Contains(t, frames[1].Data, "set_auth_token")
+	require.Equal(t, Outbound, frames[len(frames)-1].Direction)
+	require.Equal(t, "~m~4~m~~h~1", frames[len(frames)-1].Data)
+
+	replay := NewReplaySocket(frames, 0)
+	require.NoError(t, replay.Init())
+	defer replay.Close()
+	replayedBars, replayedQuote := session(replay.Socket)
+	require.Equal(t, bars, replayedBars)
+	require.Equal(t, quote, replayedQuote)
+	select {
+	case <-replay.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the replay did not finish")
+	}
+}
+
+func TestReplaySocket_Speed(t *testing.T) {
+	start := time.Now()
+	frames := []RecordedFrame{
+		{Time: start, Direction: Inbound, Data: string(protocol.Encode(protocol.Message{Payload: []byte(tvtest.Hello)}))},
+		{Time: start.Add(time.Second), Direction: Inbound, Data: string(frame(qsd("NASDAQ:MSFT", 1)))},
+	}
+	received := make(chan *QuoteData, 1)
+	replay := NewReplaySocket(frames, 10, WithOnReceiveData(func(symbol string, data *QuoteData) {
+		received <- data
+	}))
+	require.NoError(t, replay.Init())
+	defer replay.Close()
+
+	select {
+	case data := <-received:
+		require.Equal(t, 1.0, *data.Price)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the quote was not replayed")
+	}
+	elapsed := time.Since(start)
+	require.GreaterOrEqual(t, elapsed, 100*time.Millisecond)
+	require.Less(t, elapsed, time.Second)
+	<-replay.Done()
+}
+
+func frame(payload string) []byte {
+	return protocol.Encode(protocol.Message{Payload: []byte(payload)})
+}
+
+func TestReplaySocket_Passive(t *testing.T) {
+	server := tvtest.NewServer(t)
+	server.SetBars("NASDAQ:MSFT",
+		tvtest.Bar{Time: 1716290100, Close: 1.5},
+		tvtest.Bar{Time: 1716290400, Close: 2.5},
+	)
+	server.SetQuote("NASDAQ:MSFT", map[string]any{"lp": 420.5})
+
+	var recording bytes.Buffer
+	tv, err := ConnectWithOptions(context.Background(), WithURL(server.URL()), WithRecorder(&recording))
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, tv.AddSymbol("NASDAQ:MSFT"))
+	_, err = tv.CreateSeriesContext(ctx, "NASDAQ:MSFT", 10, "5", func(string, []TOHLCV) {})
+	require.NoError(t, err)
+	require.NoError(t, tv.Close())
+	frames, err := ReadRecording(&recording)
+	require.NoError(t, err)
+
+	// none of the recorded calls is made again
+	bars := make(chan []TOHLCV, 1)
+	quotes := make(chan string, 1)
+	replay := NewPassiveReplaySocket(frames, 0,
+		func(s *Socket) {
+			s.OnReceiveQuoteCallback = func(symbol string, hloc []TOHLCV) {
+				require.Equal(t, "NASDAQ:MSFT", symbol)
+				bars <- hloc
+			}
+		},
+		WithOnReceiveData(func(symbol string, data *QuoteData) {
+			quotes <- symbol
+		}),
+	)
+	require.NoError(t, replay.Init())
+	defer replay.Close()
+	select {
+	case <-replay.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the replay did not finish")
+	}
+	require.Equal(t, "NASDAQ:MSFT", <-quotes)
+	hloc := <-bars
+	require.Len(t, hloc, 2)
+	require.Equal(t, 2.5, hloc[1].Close)
+
+	series := replay.RecordedSeries()
+	require.Len(t, series, 1)
+	require.Equal(t, "sds_1", series[0].ID())
+	require.Equal(t, "5", series[0].Interval())
+	require.Len(t, series[0].Bars(), 2)
+}
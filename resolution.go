@@ -0,0 +1,85 @@
+package tvsocket
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Resolution is a bar interval in the form the server expects: a number of minutes such as "5" or "240",
+// or a count followed by S for seconds, D for days, W for weeks, M for months, T for ticks or R for ranges, e.g. "1D"
+type Resolution string
+
+// maxMinutes is the largest intraday resolution in minutes
+const maxMinutes = 1440
+
+// maxCounts bounds the count of the other units: a day of seconds, a year of days, weeks and months
+var maxCounts = map[string]int{"S": 86400, "D": 365, "W": 52, "M": 12, "T": 100000, "R": 100000}
+
+// ParseResolution converts a human resolution to the wire form: "5m" and "5" are 5 minutes, "1h" or "1H" 60 minutes,
+// "15S" 15 seconds, "1D", "1W", "3M" days, weeks and months, "100T" 100 ticks and "10R" 10 ranges.
+// Minutes use a lowercase m, months an uppercase M; the count defaults to 1, as in "D".
+// The error matches ErrInvalidResolution.
+func ParseResolution(s string) (Resolution, error) {
+	digits := len(s) - len(strings.TrimLeft(s, "0123456789"))
+	count, unit := 1, s[digits:]
+	if digits > 0 {
+		n, err := strconv.Atoi(s[:digits])
+		if err != nil || n <= 0 {
+			return "", fmt.Errorf("%w %q: the count must be a positive number", ErrInvalidResolution, s)
+		}
+		count = n
+	} else if unit == "" {
+		return "", fmt.Errorf("%w: empty resolution", ErrInvalidResolution)
+	}
+
+	switch unit {
+	case "", "m":
+		if digits == 0 {
+			break
+		}
+		if count > maxMinutes {
+			return "", fmt.Errorf("%w %q: more than %d minutes", ErrInvalidResolution, s, maxMinutes)
+		}
+		return Resolution(strconv.Itoa(count)), nil
+	case "h", "H":
+		if count > maxMinutes/60 {
+			return "", fmt.Errorf("%w %q: more than %d hours", ErrInvalidResolution, s, maxMinutes/60)
+		}
+		return Resolution(strconv.Itoa(count * 60)), nil
+	case "S", "s", "D", "d", "W", "w", "M", "T", "t", "R", "r":
+		unit = strings.ToUpper(unit)
+		if count > maxCounts[unit] {
+			return "", fmt.Errorf("%w %q: more than %d %s", ErrInvalidResolution, s, maxCounts[unit], unit)
+		}
+		return Resolution(strconv.Itoa(count) + unit), nil
+	}
+	return "", fmt.Errorf("%w %q: unknown unit %q", ErrInvalidResolution, s, unit)
+}
+
+func (r Resolution) String() string {
+	return string(r)
+}
+
+// Duration returns the length of an intraday bar, 0 for days, weeks, months, ticks and ranges
+func (r Resolution) Duration() time.Duration {
+	if minutes, err := strconv.Atoi(string(r)); err == nil {
+		return time.Duration(minutes) * time.Minute
+	}
+	if seconds, ok := strings.CutSuffix(string(r), "S"); ok {
+		if n, err := strconv.Atoi(seconds); err == nil {
+			return time.Duration(n) * time.Second
+		}
+	}
+	return 0
+}
+
+// wireInterval validates the interval given to the series methods and returns its wire form
+func wireInterval(interval string) (string, error) {
+	r, err := ParseResolution(interval)
+	if err != nil {
+		return "", err
+	}
+	return string(r), nil
+}
@@ -0,0 +1,85 @@
+package tvsocket
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ivo100/tvsocket/tvtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseResolution(t *testing.T) {
+	valid := []struct {
+		in       string
+		want     Resolution
+		duration time.Duration
+	}{
+		{"1", "1", time.Minute},
+		{"5m", "5", 5 * time.Minute},
+		{"1h", "60", time.Hour},
+		{"4H", "240", 4 * time.Hour},
+		{"24h", "1440", 24 * time.Hour},
+		{"15S", "15S", 15 * time.Second},
+		{"30s", "30S", 30 * time.Second},
+		{"D", "1D", 0},
+		{"1d", "1D", 0},
+		{"1W", "1W", 0},
+		{"3M", "3M", 0},
+		{"100T", "100T", 0},
+		{"10r", "10R", 0},
+	}
+	for _, tc := range valid {
+		r, err := ParseResolution(tc.in)
+		require.NoError(t, err, tc.in)
+		require.Equal(t, tc.want, r, tc.in)
+		require.Equal(t, tc.duration, r.Duration(), tc.in)
+	}
+
+	for _, in := range []string{"", "0", "0D", "m", "5x", "1440m1", "1441", "25h", "3mo", "-5", "1.5h",
+		"153722867280912931h", "86401S", "366D", "53W", "13M", "100001T"} {
+		_, err := ParseResolution(in)
+		require.ErrorIs(t, err, ErrInvalidResolution, in)
+	}
+}
+
+func TestPeriods_AreWireResolutions(t *testing.T) {
+	for _, period := range Periods {
+		r, err := ParseResolution(period)
+		require.NoError(t, err, period)
+		require.Equal(t, period, r.String())
+	}
+}
+
+func TestSocket_InvalidResolutionIsNotSent(t *testing.T) {
+	server := tvtest.NewServer(t)
+	tv, err := ConnectWithOptions(context.Background(), WithURL(server.URL()))
+	require.NoError(t, err)
+	defer tv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	noop := func(string, []TOHLCV) {}
+
+	_, err = tv.CreateSeries("NASDAQ:MSFT", 10, "5x", noop)
+	require.ErrorIs(t, err, ErrInvalidResolution)
+	_, err = tv.CreateSeriesContext(ctx, "NASDAQ:MSFT", 10, "0", noop)
+	require.ErrorIs(t, err, ErrInvalidResolution)
+	require.ErrorIs(t, tv.RequestQuotes("NASDAQ:AAPL", 10, "1y", noop), ErrInvalidResolution)
+	require.ErrorIs(t, tv.RequestQuotesContext(ctx, "NASDAQ:AAPL", 10, "", noop), ErrInvalidResolution)
+	_, err = tv.GetBars(ctx, "NASDAQ:MSFT", "25h", 10)
+	require.ErrorIs(t, err, ErrInvalidResolution)
+	_, err = tv.GetBarsRange(ctx, "NASDAQ:MSFT", "m", time.Now().Add(-time.Hour), time.Time{})
+	require.ErrorIs(t, err, ErrInvalidResolution)
+	_, err = tv.StreamBars(ctx, "NASDAQ:MSFT", "5 m")
+	require.ErrorIs(t, err, ErrInvalidResolution)
+	_, err = tv.StreamBarEvents(ctx, "NASDAQ:MSFT", "1Y")
+	require.ErrorIs(t, err, ErrInvalidResolution)
+
+	// the first messages of the session belong to the valid request
+	require.NoError(t, tv.RequestQuotes("NASDAQ:TSLA", 10, "1h", noop))
+	m := server.WaitFor(t, "quote_add_symbols")
+	require.Equal(t, []any{tv.quoteSessionID, "NASDAQ:TSLA"}, m.Payload)
+	m = server.WaitFor(t, "create_series")
+	require.Equal(t, []any{tv.chartSessionID, "sds_1", "s1", "sds_sym_1", "60", float64(10), ""}, m.Payload)
+}
@@ -0,0 +1,384 @@
+package tvsocket
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+)
+
+// Series is a chart series of the socket's chart session, created by CreateSeries or RequestQuotes
+type Series struct {
+	socket   *Socket
+	n        int
+	id       string
+	symbolID string
+	symbol   string
+	interval string
+	bars     int
+	// turnaround identifies the last create_series or modify_series request: s1, then s2...
+	turnaround int
+	// oneShot series are dropped instead of replayed after a reconnect
+	oneShot  bool
+	info     *SymbolInfo
+	callback OnReceiveQuoteCallback
+	waiters  waiters
+	// store is the rolling window of the bars received
+	store      barStore
+	onBarEvent func(event BarEvent)
+}
+
+// ID returns the series id used on the wire, e.g. sds_1
+func (sr *Series) ID() string {
+	return sr.id
+}
+
+// Symbol ...
+func (sr *Series) Symbol() string {
+	sr.socket.mu.Lock()
+	defer sr.socket.mu.Unlock()
+	return sr.symbol
+}
+
+// Interval ...
+func (sr *Series) Interval() string {
+	sr.socket.mu.Lock()
+	defer sr.socket.mu.Unlock()
+	return sr.interval
+}
+
+// Info returns the metadata of the symbol, nil until the server has resolved it
+func (sr *Series) Info() *SymbolInfo {
+	sr.socket.mu.Lock()
+	defer sr.socket.mu.Unlock()
+	return sr.info
+}
+
+// SetResolution switches the series to another interval with modify_series.
+// The bar window is cleared and the history at the new interval is delivered to the callback of the series.
+func (sr *Series) SetResolution(interval string) (err error) {
+	if interval, err = wireInterval(interval); err != nil {
+		return
+	}
+	s := sr.socket
+	s.mu.Lock()
+	if s.series[sr.id] != sr {
+		s.mu.Unlock()
+		return errors.New("the series has been removed")
+	}
+	sr.interval = interval
+	sr.turnaround++
+	sr.store.reset()
+	symbolID, turnaround := sr.symbolID, sr.turnaroundID()
+	s.mu.Unlock()
+	return s.sendModifySeries(sr, turnaround, symbolID, interval)
+}
+
+// SetSymbol switches the series to another symbol: the symbol is resolved under a new symbol id, then the series
+// is modified with modify_series. The bar window is cleared and the history of the symbol is delivered to the callback
+// of the series; Info returns nil until the symbol has been resolved.
+func (sr *Series) SetSymbol(symbol string) (err error) {
+	s := sr.socket
+	s.mu.Lock()
+	if s.series[sr.id] != sr {
+		s.mu.Unlock()
+		return errors.New("the series has been removed")
+	}
+	sr.symbol = symbol
+	sr.info = nil
+	sr.turnaround++
+	sr.symbolID = "sds_sym_" + strconv.Itoa(sr.n) + "_" + strconv.Itoa(sr.turnaround)
+	sr.store.reset()
+	symbolID, turnaround, interval := sr.symbolID, sr.turnaroundID(), sr.interval
+	s.mu.Unlock()
+
+	err = s.sendSocketMessage(getSocketMessage("resolve_symbol", []any{
+		s.chartSessionID,
+		symbolID,
+		symbolArg(symbol),
+	}))
+	if err != nil {
+		return
+	}
+	return s.sendModifySeries(sr, turnaround, symbolID, interval)
+}
+
+// turnaroundID returns the wire id of the last request of the series, it must be called with the lock held
+func (sr *Series) turnaroundID() string {
+	return "s" + strconv.Itoa(sr.turnaround)
+}
+
+// CreateSeries adds a new series to the chart session, every update of the series is delivered to onReceiveQuote
+func (s *Socket) CreateSeries(symbol string, bars int, interval string, onReceiveQuote OnReceiveQuoteCallback) (series *Series, err error) {
+	if interval, err = wireInterval(interval); err != nil {
+		return
+	}
+	series = s.newSeries(symbol, bars, interval, onReceiveQuote)
+	if err = s.sendSeries(series); err != nil {
+		_ = series.Remove()
+		return nil, err
+	}
+	return
+}
+
+// CreateSeriesContext adds a new series like CreateSeries and waits until its history has been delivered.
+// Called from a callback it fails with ErrCalledFromCallback.
+func (s *Socket) CreateSeriesContext(ctx context.Context, symbol string, bars int, interval string, onReceiveQuote OnReceiveQuoteCallback) (series *Series, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	if err = s.checkNotInLoop(); err != nil {
+		return
+	}
+	if interval, err = wireInterval(interval); err != nil {
+		return
+	}
+	series = s.newSeries(symbol, bars, interval, onReceiveQuote)
+	completed := series.wait()
+	if err = s.sendSeries(series); err == nil {
+		err = series.waitCompleted(ctx, completed)
+	}
+	if err != nil {
+		_ = series.Remove()
+		return nil, err
+	}
+	return
+}
+
+// Remove deletes the series from the chart session
+func (sr *Series) Remove() (err error) {
+	s := sr.socket
+	s.mu.Lock()
+	_, ok := s.series[sr.id]
+	delete(s.series, sr.id)
+	s.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	removed := errors.New("the series has been removed")
+	sr.notify(removed)
+	s.dropStudies(sr, removed)
+	return s.sendSocketMessage(getSocketMessage("remove_series", []any{s.chartSessionID, sr.id}))
+}
+
+// newSeries allocates unique series and symbol ids and registers the series
+func (s *Socket) newSeries(symbol string, bars int, interval string, onReceiveQuote OnReceiveQuoteCallback) *Series {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seriesCounter++
+	n := strconv.Itoa(s.seriesCounter)
+	series := &Series{
+		socket:     s,
+		n:          s.seriesCounter,
+		id:         "sds_" + n,
+		symbolID:   "sds_sym_" + n,
+		symbol:     symbol,
+		interval:   interval,
+		bars:       bars,
+		turnaround: 1,
+		callback:   onReceiveQuote,
+		store:      barStore{window: s.config.barWindow},
+	}
+	if s.series == nil {
+		s.series = make(map[string]*Series)
+	}
+	s.series[series.id] = series
+	return series
+}
+
+// sendSeries resolves the symbol and creates the series on the server
+func (s *Socket) sendSeries(series *Series) (err error) {
+	s.mu.Lock()
+	symbol, interval, bars := series.symbol, series.interval, series.bars
+	symbolID, turnaround := series.symbolID, series.turnaroundID()
+	s.mu.Unlock()
+
+	err = s.sendSocketMessage(getSocketMessage("resolve_symbol", []any{
+		s.chartSessionID,
+		symbolID,
+		symbolArg(symbol),
+	}))
+	if err != nil {
+		return
+	}
+	return s.sendSocketMessage(getSocketMessage("create_series", []any{
+		s.chartSessionID,
+		series.id,
+		turnaround,
+		symbolID,
+		interval,
+		bars,
+		"",
+	}))
+}
+
+// sendModifySeries points the series to the symbol id and the interval
+func (s *Socket) sendModifySeries(series *Series, turnaround string, symbolID string, interval string) error {
+	return s.sendSocketMessage(getSocketMessage("modify_series", []any{
+		s.chartSessionID,
+		series.id,
+		turnaround,
+		symbolID,
+		interval,
+		"",
+	}))
+}
+
+// findSeries looks a series up by its series or symbol id
+func (s *Socket) findSeries(id string) *Series {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if series, ok := s.series[id]; ok {
+		return series
+	}
+	for _, series := range s.series {
+		if series.symbolID == id {
+			return series
+		}
+	}
+	return nil
+}
+
+// activeSeries returns the registered series in creation order
+func (s *Socket) activeSeries() []*Series {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	series := make([]*Series, 0, len(s.series))
+	for _, v := range s.series {
+		series = append(series, v)
+	}
+	sort.Slice(series, func(i, j int) bool {
+		return series[i].n < series[j].n
+	})
+	return series
+}
+
+// onSeriesBars delivers the bars of an update, the ones answering a previous turnaround of the series are dropped
+func (s *Socket) onSeriesBars(id string, turnaround string, hloc []TOHLCV, indexes []int) {
+	series := s.findSeries(id)
+	if series == nil {
+		return
+	}
+	s.mu.Lock()
+	if turnaround != "" && turnaround != series.turnaroundID() {
+		s.mu.Unlock()
+		return
+	}
+	symbol, interval := series.symbol, series.interval
+	callback := series.callback
+	if callback == nil {
+		callback = s.OnReceiveQuoteCallback
+	}
+	events := series.store.apply(indexes, hloc)
+	onBarEvent := series.onBarEvent
+	s.mu.Unlock()
+	if callback != nil {
+		callback(symbol, hloc)
+	}
+	for _, event := range events {
+		event.Symbol, event.Interval = symbol, interval
+		if onBarEvent != nil {
+			onBarEvent(event)
+		}
+		if s.OnBarEventCallback != nil {
+			s.OnBarEventCallback(series, event)
+		}
+	}
+}
+
+func (s *Socket) onSeriesStatus(status *seriesStatus) {
+	series := s.findSeries(status.seriesID)
+	if series == nil {
+		if s.onStudyStatus(status) {
+			return
+		}
+		if status.err != nil {
+			s.answerResolver(status.seriesID, resolveResult{err: status.err})
+		}
+		return
+	}
+	s.mu.Lock()
+	stale := status.turnaround != "" && status.turnaround != series.turnaroundID()
+	s.mu.Unlock()
+	if stale {
+		return
+	}
+	if seriesErr, ok := status.err.(*SeriesError); ok {
+		seriesErr.Symbol = series.Symbol()
+	}
+	series.notify(status.err)
+}
+
+// dropOneShotSeries ends the pending requests of the series that are not replayed after a reconnect
+func (s *Socket) dropOneShotSeries(err error) {
+	var dropped []*Series
+	s.mu.Lock()
+	for id, series := range s.series {
+		if series.oneShot {
+			delete(s.series, id)
+			dropped = append(dropped, series)
+		}
+	}
+	s.mu.Unlock()
+	for _, series := range dropped {
+		series.notify(err)
+		s.dropStudies(series, err)
+	}
+}
+
+// waiters are the callers waiting for a series or a study to be completed or rejected, guarded by the socket mutex
+type waiters []chan error
+
+// wait registers a waiter notified when the series is completed or rejected
+func (sr *Series) wait() chan error {
+	return sr.socket.addWaiter(&sr.waiters)
+}
+
+func (sr *Series) waitCompleted(ctx context.Context, completed chan error) error {
+	return sr.socket.waitCompleted(ctx, &sr.waiters, completed, SeriesErrorContext)
+}
+
+// notify wakes up every waiter of the series
+func (sr *Series) notify(err error) {
+	sr.socket.notifyWaiters(&sr.waiters, err)
+}
+
+func (s *Socket) addWaiter(w *waiters) chan error {
+	completed := make(chan error, 1)
+	s.mu.Lock()
+	*w = append(*w, completed)
+	s.mu.Unlock()
+	return completed
+}
+
+func (s *Socket) waitCompleted(ctx context.Context, w *waiters, completed chan error, context string) error {
+	defer s.removeWaiter(w, completed)
+	select {
+	case err := <-completed:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.ctx.Done():
+		return newError(ErrConnectionClosed, context, nil)
+	}
+}
+
+func (s *Socket) removeWaiter(w *waiters, completed chan error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, v := range *w {
+		if v == completed {
+			*w = append((*w)[:i], (*w)[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *Socket) notifyWaiters(w *waiters, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, v := range *w {
+		v <- err
+	}
+	*w = nil
+}
@@ -0,0 +1,173 @@
+package tvsocket
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ivo100/tvsocket/tvtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSocket_MultipleSeries(t *testing.T) {
+	server := tvtest.NewServer(t)
+	tv, err := ConnectWithOptions(context.Background(), WithURL(server.URL()))
+	require.NoError(t, err)
+	defer tv.Close()
+
+	var mu sync.Mutex
+	received := make(map[string][]TOHLCV)
+	updates := make(chan struct{}, 10)
+	onReceive := func(symbol string, hloc []TOHLCV) {
+		mu.Lock()
+		received[symbol] = append(received[symbol], hloc...)
+		mu.Unlock()
+		updates <- struct{}{}
+	}
+
+	msft, err := tv.CreateSeries("NASDAQ:MSFT", 10, "5", onReceive)
+	require.NoError(t, err)
+	aapl, err := tv.CreateSeries("NASDAQ:AAPL", 10, "5", onReceive)
+	require.NoError(t, err)
+	require.Equal(t, "sds_1", msft.ID())
+	require.Equal(t, "sds_2", aapl.ID())
+	require.Equal(t, "NASDAQ:AAPL", aapl.Symbol())
+
+	m := server.WaitFor(t, "create_series")
+	require.Equal(t, []any{tv.chartSessionID, "sds_1", "s1", "sds_sym_1", "5", float64(10), ""}, m.Payload)
+	server.WaitFor(t, "create_series")
+
+	server.Send(
+		`{"m":"timescale_update","p":["cs_x",{"sds_1":{"s":[{"i":0,"v":[1716290100.0,1,1,1,1,1]}]},"sds_2":{"s":[{"i":0,"v":[1716290100.0,2,2,2,2,2]}]}}]}`,
+		`{"m":"du","p":["cs_x",{"sds_2":{"s":[{"i":0,"v":[1716290100.0,2,3,2,3,5]}]}}]}`,
+	)
+	for i := 0; i < 3; i++ {
+		select {
+		case <-updates:
+		case <-time.After(5 * time.Second):
+			t.Fatal("missing series updates")
+		}
+	}
+
+	mu.Lock()
+	require.Len(t, received["NASDAQ:MSFT"], 1)
+	require.Equal(t, 1.0, received["NASDAQ:MSFT"][0].Close)
+	require.Len(t, received["NASDAQ:AAPL"], 2)
+	require.Equal(t, 3.0, received["NASDAQ:AAPL"][1].Close)
+	mu.Unlock()
+
+	require.NoError(t, msft.Remove())
+	m = server.WaitFor(t, "remove_series")
+	require.Equal(t, "sds_1", m.Payload[1])
+	require.Nil(t, tv.findSeries("sds_1"))
+	require.NotNil(t, tv.findSeries("sds_sym_2"))
+}
+
+func TestSocket_CreateSeriesSendError(t *testing.T) {
+	server := tvtest.NewServer(t)
+	tv, err := ConnectWithOptions(context.Background(), WithURL(server.URL()))
+	require.NoError(t, err)
+	require.NoError(t, tv.Close())
+
+	_, err = tv.CreateSeries("NASDAQ:MSFT", 10, "5", nil)
+	require.ErrorIs(t, err, ErrConnectionClosed)
+	err = tv.RequestQuotes("NASDAQ:MSFT", 10, "5", nil)
+	require.ErrorIs(t, err, ErrConnectionClosed)
+	require.Empty(t, tv.activeSeries())
+}
+
+func TestSeries_SetResolutionAndSymbol(t *testing.T) {
+	server := tvtest.NewServer(t)
+	server.SetBars("NASDAQ:MSFT",
+		tvtest.Bar{Time: 1716290100, Close: 1},
+		tvtest.Bar{Time: 1716290400, Close: 2},
+		tvtest.Bar{Time: 1716290700, Close: 3},
+	)
+	server.SetBars("NASDAQ:AAPL",
+		tvtest.Bar{Time: 1716290100, Close: 10},
+		tvtest.Bar{Time: 1716290400, Close: 20},
+	)
+	tv, err := ConnectWithOptions(context.Background(), WithURL(server.URL()))
+	require.NoError(t, err)
+	defer tv.Close()
+
+	updates := make(chan []TOHLCV, 10)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	series, err := tv.CreateSeriesContext(ctx, "NASDAQ:MSFT", 10, "5", func(symbol string, hloc []TOHLCV) {
+		updates <- hloc
+	})
+	require.NoError(t, err)
+	require.Len(t, <-updates, 3)
+
+	require.ErrorIs(t, series.SetResolution("5x"), ErrInvalidResolution)
+	require.NoError(t, series.SetResolution("1h"))
+	m := server.WaitFor(t, "modify_series")
+	require.Equal(t, []any{tv.chartSessionID, "sds_1", "s2", "sds_sym_1", "60", ""}, m.Payload)
+	require.Equal(t, "60", series.Interval())
+	select {
+	case hloc := <-updates:
+		require.Len(t, hloc, 3)
+	case <-time.After(5 * time.Second):
+		t.Fatal("missing history after modify_series")
+	}
+
+	require.NoError(t, series.SetSymbol("NASDAQ:AAPL"))
+	m = server.WaitFor(t, "resolve_symbol")
+	require.Equal(t, "sds_sym_1_3", m.Payload[1])
+	m = server.WaitFor(t, "modify_series")
+	require.Equal(t, []any{tv.chartSessionID, "sds_1", "s3", "sds_sym_1_3", "60", ""}, m.Payload)
+	require.Equal(t, "NASDAQ:AAPL", series.Symbol())
+	select {
+	case hloc := <-updates:
+		require.Equal(t, []float64{10, 20}, []float64{hloc[0].Close, hloc[1].Close})
+	case <-time.After(5 * time.Second):
+		t.Fatal("missing history after modify_series")
+	}
+	require.Len(t, series.Bars(), 2)
+
+	// the bars answering an earlier turnaround are dropped
+	server.Send(
+		`{"m":"timescale_update","p":["cs_x",{"sds_1":{"s":[{"i":5,"v":[1716291600.0,1,1,1,1,1]}],"t":"s2"}}]}`,
+		`{"m":"du","p":["cs_x",{"sds_1":{"s":[{"i":2,"v":[1716290700.0,30,30,30,30,1]}]}}]}`,
+	)
+	select {
+	case hloc := <-updates:
+		require.Equal(t, 30.0, hloc[0].Close)
+	case <-time.After(5 * time.Second):
+		t.Fatal("missing update")
+	}
+	require.Len(t, series.Bars(), 3)
+	last, _ := series.LastIndex()
+	require.Equal(t, 2, last)
+
+	require.NoError(t, series.Remove())
+	require.Error(t, series.SetSymbol("NASDAQ:TSLA"))
+}
+
+func TestSocket_SeriesOfAnUpdateAreDispatchedInOrder(t *testing.T) {
+	server := tvtest.NewServer(t)
+	tv, err := ConnectWithOptions(context.Background(), WithURL(server.URL()))
+	require.NoError(t, err)
+	defer tv.Close()
+
+	received := make(chan string, 10)
+	for _, symbol := range []string{"A", "B", "C", "D", "E"} {
+		_, err := tv.CreateSeries("NASDAQ:"+symbol, 10, "5", func(symbol string, hloc []TOHLCV) {
+			received <- symbol
+		})
+		require.NoError(t, err)
+	}
+
+	bar := `{"s":[{"i":0,"v":[1716290100.0,1,1,1,1,1]}]}`
+	server.Send(`{"m":"du","p":["cs_x",{"sds_5":` + bar + `,"sds_2":` + bar + `,"sds_4":` + bar + `,"sds_1":` + bar + `,"sds_3":` + bar + `}]}`)
+	for _, symbol := range []string{"E", "B", "D", "A", "C"} {
+		select {
+		case got := <-received:
+			require.Equal(t, "NASDAQ:"+symbol, got)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("NASDAQ:%s was not received", symbol)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package tvsocket
+
+import (
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+)
+
+// QuoteField is a bitmask of QuoteData fields
+type QuoteField uint64
+
+const (
+	FieldPrice QuoteField = 1 << iota
+	FieldPrevClosePrice
+	FieldRegularClosePrice
+	FieldRegularCloseTime
+	FieldHighPrice
+	FieldLowPrice
+	FieldOpenPrice
+	FieldOpenTime
+	FieldVolume
+	FieldBid
+	FieldAsk
+	FieldChange
+	FieldTime
+	FieldChangePercent
+	FieldBidSize
+	FieldAskSize
+	FieldExtendedPrice
+	FieldExtendedTime
+	FieldExtendedChange
+	FieldExtendedChangePercent
+	FieldAllTimeHigh
+	FieldAllTimeLow
+	FieldDescription
+	FieldShortName
+	FieldProName
+	FieldOriginalName
+	FieldExchange
+	FieldListedExchange
+	FieldType
+	FieldTypeSpecs
+	FieldCurrencyCode
+	FieldTimezone
+	FieldCurrentSession
+	FieldUpdateMode
+	FieldFractional
+	FieldPriceScale
+	FieldMinMov
+	FieldPointValue
+	FieldMarketCapBasic
+	FieldTotalSharesOutstanding
+	FieldSource2
+	// FieldExtra is set when any key of Extra changed
+	FieldExtra
+)
+
+// Has reports whether every field of fields is set
+func (f QuoteField) Has(fields QuoteField) bool {
+	return f&fields == fields
+}
+
+// Merge folds the fields set in delta into q and returns the fields whose value changed
+func (q *QuoteData) Merge(delta *QuoteData) (changed QuoteField) {
+	if delta == nil {
+		return
+	}
+	mergePtr(&q.Price, delta.Price, FieldPrice, &changed)
+	mergePtr(&q.PrevClosePrice, delta.PrevClosePrice, FieldPrevClosePrice, &changed)
+	mergePtr(&q.RegularClosePrice, delta.RegularClosePrice, FieldRegularClosePrice, &changed)
+	mergePtr(&q.RegularCloseTime, delta.RegularCloseTime, FieldRegularCloseTime, &changed)
+	mergePtr(&q.HighPrice, delta.HighPrice, FieldHighPrice, &changed)
+	mergePtr(&q.LowPrice, delta.LowPrice, FieldLowPrice, &changed)
+	mergePtr(&q.OpenPrice, delta.OpenPrice, FieldOpenPrice, &changed)
+	mergePtr(&q.OpenTime, delta.OpenTime, FieldOpenTime, &changed)
+	mergePtr(&q.Volume, delta.Volume, FieldVolume, &changed)
+	mergePtr(&q.Bid, delta.Bid, FieldBid, &changed)
+	mergePtr(&q.Ask, delta.Ask, FieldAsk, &changed)
+	mergePtr(&q.Change, delta.Change, FieldChange, &changed)
+	mergePtr(&q.Time, delta.Time, FieldTime, &changed)
+	mergePtr(&q.ChangePercent, delta.ChangePercent, FieldChangePercent, &changed)
+	mergePtr(&q.BidSize, delta.BidSize, FieldBidSize, &changed)
+	mergePtr(&q.AskSize, delta.AskSize, FieldAskSize, &changed)
+	mergePtr(&q.ExtendedPrice, delta.ExtendedPrice, FieldExtendedPrice, &changed)
+	mergePtr(&q.ExtendedTime, delta.ExtendedTime, FieldExtendedTime, &changed)
+	mergePtr(&q.ExtendedChange, delta.ExtendedChange, FieldExtendedChange, &changed)
+	mergePtr(&q.ExtendedChangePercent, delta.ExtendedChangePercent, FieldExtendedChangePercent, &changed)
+	mergePtr(&q.AllTimeHigh, delta.AllTimeHigh, FieldAllTimeHigh, &changed)
+	mergePtr(&q.AllTimeLow, delta.AllTimeLow, FieldAllTimeLow, &changed)
+	mergePtr(&q.Description, delta.Description, FieldDescription, &changed)
+	mergePtr(&q.ShortName, delta.ShortName, FieldShortName, &changed)
+	mergePtr(&q.ProName, delta.ProName, FieldProName, &changed)
+	mergePtr(&q.OriginalName, delta.OriginalName, FieldOriginalName, &changed)
+	mergePtr(&q.Exchange, delta.Exchange, FieldExchange, &changed)
+	mergePtr(&q.ListedExchange, delta.ListedExchange, FieldListedExchange, &changed)
+	mergePtr(&q.Type, delta.Type, FieldType, &changed)
+	mergePtr(&q.CurrencyCode, delta.CurrencyCode, FieldCurrencyCode, &changed)
+	mergePtr(&q.Timezone, delta.Timezone, FieldTimezone, &changed)
+	mergePtr(&q.CurrentSession, delta.CurrentSession, FieldCurrentSession, &changed)
+	mergePtr(&q.UpdateMode, delta.UpdateMode, FieldUpdateMode, &changed)
+	mergePtr(&q.Fractional, delta.Fractional, FieldFractional, &changed)
+	mergePtr(&q.PriceScale, delta.PriceScale, FieldPriceScale, &changed)
+	mergePtr(&q.MinMov, delta.MinMov, FieldMinMov, &changed)
+	mergePtr(&q.PointValue, delta.PointValue, FieldPointValue, &changed)
+	mergePtr(&q.MarketCapBasic, delta.MarketCapBasic, FieldMarketCapBasic, &changed)
+	mergePtr(&q.TotalSharesOutstanding, delta.TotalSharesOutstanding, FieldTotalSharesOutstanding, &changed)
+	mergePtr(&q.Source2, delta.Source2, FieldSource2, &changed)
+	if delta.TypeSpecs != nil && (q.TypeSpecs == nil || !slices.Equal(q.TypeSpecs, delta.TypeSpecs)) {
+		q.TypeSpecs = slices.Clone(delta.TypeSpecs)
+		changed |= FieldTypeSpecs
+	}
+	for k, v := range delta.Extra {
+		if old, ok := q.Extra[k]; ok && reflect.DeepEqual(old, v) {
+			continue
+		}
+		if q.Extra == nil {
+			q.Extra = make(map[string]any, len(delta.Extra))
+		}
+		q.Extra[k] = v
+		changed |= FieldExtra
+	}
+	return
+}
+
+// Clone returns a copy of q that shares no pointer with it
+func (q *QuoteData) Clone() *QuoteData {
+	c := &QuoteData{}
+	c.Merge(q)
+	return c
+}
+
+// mergePtr copies *src into a new value of dst when src is set and differs from dst
+func mergePtr[T comparable](dst **T, src *T, field QuoteField, changed *QuoteField) {
+	if src == nil || (*dst != nil && **dst == *src) {
+		return
+	}
+	v := *src
+	*dst = &v
+	*changed |= field
+}
+
+// QuoteSnapshot is the merged view of every qsd received for a symbol
+type QuoteSnapshot struct {
+	Symbol string
+	Data   *QuoteData
+	// Changed holds the fields changed by the last update
+	Changed QuoteField
+	Updated time.Time
+}
+
+// snapshots keeps a QuoteSnapshot per symbol
+type snapshots struct {
+	mu sync.RWMutex
+	m  map[string]*QuoteSnapshot
+}
+
+// merge folds the delta into the snapshot of the symbol and returns the changed fields
+func (ss *snapshots) merge(symbol string, delta *QuoteData) QuoteField {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if ss.m == nil {
+		ss.m = make(map[string]*QuoteSnapshot)
+	}
+	snapshot, ok := ss.m[symbol]
+	if !ok {
+		snapshot = &QuoteSnapshot{Symbol: symbol, Data: &QuoteData{}}
+		ss.m[symbol] = snapshot
+	}
+	snapshot.Changed = snapshot.Data.Merge(delta)
+	snapshot.Updated = time.Now()
+	return snapshot.Changed
+}
+
+func (ss *snapshots) get(symbol string) (QuoteSnapshot, bool) {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	snapshot, ok := ss.m[symbol]
+	if !ok {
+		return QuoteSnapshot{}, false
+	}
+	return snapshot.copy(), true
+}
+
+func (ss *snapshots) all() map[string]QuoteSnapshot {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	all := make(map[string]QuoteSnapshot, len(ss.m))
+	for symbol, snapshot := range ss.m {
+		all[symbol] = snapshot.copy()
+	}
+	return all
+}
+
+func (ss *snapshots) remove(symbol string) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	delete(ss.m, symbol)
+}
+
+func (sn *QuoteSnapshot) copy() QuoteSnapshot {
+	c := *sn
+	c.Data = sn.Data.Clone()
+	return c
+}
+
+// Snapshot returns the merged quote of the symbol, false until its first qsd has been received
+func (s *Socket) Snapshot(symbol string) (QuoteSnapshot, bool) {
+	return s.snapshots.get(symbol)
+}
+
+// Snapshots returns the merged quote of every symbol received so far
+func (s *Socket) Snapshots() map[string]QuoteSnapshot {
+	return s.snapshots.all()
+}
@@ -0,0 +1,87 @@
+package tvsocket
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ivo100/tvsocket/tvtest"
+	"github.com/stretchr/testify/require"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestQuoteData_Merge(t *testing.T) {
+	q := &QuoteData{}
+	changed := q.Merge(&QuoteData{Price: ptr(1.0), Bid: ptr(0.9), Description: ptr("Microsoft"), TypeSpecs: []string{"common"}})
+	require.Equal(t, FieldPrice|FieldBid|FieldDescription|FieldTypeSpecs, changed)
+
+	delta := &QuoteData{Price: ptr(1.0), Ask: ptr(1.1), Extra: map[string]any{"logoid": "microsoft"}}
+	changed = q.Merge(delta)
+	require.Equal(t, FieldAsk|FieldExtra, changed)
+	require.True(t, changed.Has(FieldAsk))
+	require.False(t, changed.Has(FieldPrice))
+	require.Equal(t, 1.0, *q.Price)
+	require.Equal(t, 0.9, *q.Bid)
+	require.Equal(t, 1.1, *q.Ask)
+	require.Equal(t, "microsoft", q.Extra["logoid"])
+
+	// the merged values are copies
+	*delta.Ask = 2
+	require.Equal(t, 1.1, *q.Ask)
+	require.Zero(t, q.Merge(&QuoteData{Extra: map[string]any{"logoid": "microsoft"}}))
+	require.Zero(t, q.Merge(nil))
+}
+
+func TestSocket_Snapshots(t *testing.T) {
+	server := tvtest.NewServer(t)
+	received := make(chan QuoteSnapshot, 10)
+	tv, err := ConnectWithOptions(context.Background(),
+		WithURL(server.URL()),
+		WithOnSnapshot(func(snapshot QuoteSnapshot) {
+			received <- snapshot
+		}),
+	)
+	require.NoError(t, err)
+	defer tv.Close()
+
+	_, ok := tv.Snapshot("NASDAQ:MSFT")
+	require.False(t, ok)
+
+	server.Send(`{"m":"qsd","p":["qs_x",{"n":"NASDAQ:MSFT","s":"ok","v":{"lp":420,"bid":419.9,"description":"Microsoft"}}]}`)
+	server.Send(`{"m":"qsd","p":["qs_x",{"n":"NASDAQ:MSFT","s":"ok","v":{"lp":420.5}}]}`)
+	server.Send(`{"m":"qsd","p":["qs_x",{"n":"NASDAQ:AAPL","s":"ok","v":{"lp":190}}]}`)
+
+	var last QuoteSnapshot
+	for i := 0; i < 3; i++ {
+		select {
+		case snapshot := <-received:
+			if snapshot.Symbol == "NASDAQ:MSFT" {
+				last = snapshot
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("the snapshot was not received")
+		}
+	}
+	require.Equal(t, FieldPrice, last.Changed)
+	require.Equal(t, 420.5, *last.Data.Price)
+	require.Equal(t, 419.9, *last.Data.Bid)
+	require.Equal(t, "Microsoft", *last.Data.Description)
+
+	snapshot, ok := tv.Snapshot("NASDAQ:MSFT")
+	require.True(t, ok)
+	require.Equal(t, 420.5, *snapshot.Data.Price)
+	*snapshot.Data.Price = 0
+	snapshot, _ = tv.Snapshot("NASDAQ:MSFT")
+	require.Equal(t, 420.5, *snapshot.Data.Price)
+
+	all := tv.Snapshots()
+	require.Len(t, all, 2)
+	require.Equal(t, 190.0, *all["NASDAQ:AAPL"].Data.Price)
+
+	require.NoError(t, tv.RemoveSymbol("NASDAQ:AAPL"))
+	_, ok = tv.Snapshot("NASDAQ:AAPL")
+	require.False(t, ok)
+}
@@ -1,14 +1,17 @@
 package tvsocket
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/gorilla/websocket"
-	"github.com/mitchellh/mapstructure"
+	"github.com/ivo100/tvsocket/protocol"
 	"net/http"
-	"strconv"
-	"strings"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
@@ -20,14 +23,53 @@ type Socket struct {
 	OnReceiveMarketDataCallback OnReceiveDataCallback
 	OnErrorCallback             OnErrorCallback
 	OnReceiveQuoteCallback      OnReceiveQuoteCallback
-	conn                        *websocket.Conn
-	isClosed                    bool
-	quoteSessionID              string
-	chartSessionID              string
-	chartSessionName            string
-	Symbol                      string
+	OnReconnectCallback         OnReconnectCallback
+	OnReceiveSnapshotCallback   OnReceiveSnapshotCallback
+	OnReceiveStudyCallback      OnReceiveStudyCallback
+	OnBarEventCallback          OnBarEventCallback
+	// Reconnect enables the reconnect supervisor when not nil
+	Reconnect *ReconnectPolicy
+	config    config
+	// mu guards the connection and the state shared with the read loop
+	mu             sync.Mutex
+	conn           *connection
+	isClosed       bool
+	ctx            context.Context
+	cancel         context.CancelFunc
+	loopDone       chan struct{}
+	inbox          chan []byte
+	seriesCounter  int
+	quoteSessionID string
+	chartSessionID string
+	quoteStreams   []*quoteStream
+	snapshots      snapshots
+	// Deprecated: a socket can hold several series, see Series.Symbol
+	Symbol string
+	// state replayed after a reconnect
+	fields  []string
+	symbols []string
+	series  map[string]*Series
+	// fastSymbols get real-time updates unless the quote session is hibernating
+	fastSymbols []string
+	hibernating bool
+
+	// pending ResolveSymbol calls keyed by symbol id
+	resolveCounter int
+	resolvers      map[string]chan resolveResult
+	// dispatched is called after each packet taken from the inbox has been parsed
+	dispatched func()
+
+	studyCounter int
+	studies      map[string]*Study
+	// tokenTimer sends a fresh auth token before the current one expires
+	tokenTimer *time.Timer
+	// the goroutines running the loops, where the blocking methods can't wait for a reply
+	dispatchGoroutine   atomic.Uint64
+	connectionGoroutine atomic.Uint64
 }
 
+var _ SocketContextInterface = (*Socket)(nil)
+
 // Connect - Connects and returns the trading view socket object
 func Connect(
 	onReceiveMarketDataCallback OnReceiveDataCallback,
@@ -46,148 +88,238 @@ func Connect(
 
 // Init connects to the tradingview web socket
 func (s *Socket) Init(fields ...string) (err error) {
+	return s.init(context.Background(), fields...)
+}
+
+// InitContext connects to the tradingview web socket.
+// ctx bounds the dial and the handshake only, like net.Dialer.DialContext: once connected the socket lives until Close.
+func (s *Socket) InitContext(ctx context.Context, fields ...string) (err error) {
+	return s.init(ctx, fields...)
+}
+
+func (s *Socket) init(ctx context.Context, fields ...string) (err error) {
+	s.mu.Lock()
 	s.isClosed = true
-	s.chartSessionName = "price"
+	s.mu.Unlock()
+	s.fields = fields
 	s.quoteSessionID = s.generateSessionID(true)
 	s.chartSessionID = s.generateSessionID(false)
 	//fmt.Printf("Session IDs: %s %s\n", s.quoteSessionID, s.chartSessionID)
-	//fmt.Printf("Connecting to %s\n", TradingViewSocketURL)
-	if s.conn, _, err = (&websocket.Dialer{}).Dial(TradingViewSocketURL, getHeaders()); err != nil {
+	// the socket outlives ctx, only Close and CloseContext end it
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	if err = s.connect(ctx); err != nil {
 		if s.OnErrorCallback != nil {
 			s.onError(err, InitErrorContext)
 		}
+		s.cancel()
 		return err
 	}
 
-	if err = s.checkFirstReceivedMessage(); err != nil {
-		if s.OnErrorCallback != nil {
-			s.onError(err, InitErrorContext)
-		}
-		return err
+	s.mu.Lock()
+	s.isClosed = false
+	s.mu.Unlock()
+	s.loopDone = make(chan struct{})
+	s.inbox = make(chan []byte, 256)
+	go s.dispatchLoop()
+	go s.connectionLoop()
+
+	return
+}
+
+// connect dials the socket and sets up the quote and chart sessions.
+// The connection is only published once the setup messages have been queued,
+// so nothing sent by the caller can overtake them.
+func (s *Socket) connect(ctx context.Context) (err error) {
+	//fmt.Printf("Connecting to %s\n", s.config.socketURL())
+	conn, err := s.config.dial(ctx)
+	if err != nil {
+		return newError(ErrHandshake, InitErrorContext, err)
 	}
+	c := newConnection(conn, s.config.recorder)
 
-	if err = s.sendConnectionSetupMessages(fields...); err != nil {
-		if s.OnErrorCallback != nil {
-			s.onError(err, InitErrorContext)
+	// the handshake below blocks on the connection, closing it is the only way to abort
+	stop := context.AfterFunc(ctx, func() {
+		_ = c.close()
+	})
+	defer stop()
+
+	var expires time.Time
+	if err = s.checkFirstReceivedMessage(c); err == nil {
+		var token string
+		if token, expires, err = s.authToken(ctx); err == nil {
+			err = s.sendConnectionSetupMessages(c, token, s.fields...)
 		}
+	}
+	if ctx.Err() != nil {
+		err = newError(ErrHandshake, InitErrorContext, ctx.Err())
+	}
+	if err != nil {
+		_ = c.close()
 		return err
 	}
 
-	s.isClosed = false
-	go s.connectionLoop()
+	s.mu.Lock()
+	s.conn = c
+	s.mu.Unlock()
+	s.scheduleTokenRefresh(expires)
+	return nil
+}
 
-	return
+// connection returns the current connection, nil before Init
+func (s *Socket) connection() *connection {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn
 }
 
 // Close ...
 func (s *Socket) Close() (err error) {
-	if s.isClosed {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.isClosed || s.conn == nil {
 		return nil
 	}
 	s.isClosed = true
-	return s.conn.Close()
+	s.cancel()
+	if s.tokenTimer != nil {
+		s.tokenTimer.Stop()
+	}
+	return s.conn.close()
+}
+
+// CloseContext sends a close frame and waits for the server to acknowledge it before closing the connection.
+// When ctx is done first the connection is closed right away and ctx.Err() is returned.
+// Called from a callback it does not wait for the acknowledgement.
+func (s *Socket) CloseContext(ctx context.Context) (err error) {
+	s.mu.Lock()
+	if s.isClosed || s.conn == nil {
+		s.mu.Unlock()
+		return nil
+	}
+	s.isClosed = true
+	c := s.conn
+	s.mu.Unlock()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(time.Second)
+	}
+	msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	// WriteControl may be called concurrently with the writer goroutine
+	writeErr := c.conn.WriteControl(websocket.CloseMessage, msg, deadline)
+	// the acknowledgement is read by the connection goroutine, a callback can't wait for it
+	if writeErr == nil && s.checkNotInLoop() == nil {
+		select {
+		case <-s.loopDone:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+
+	s.cancel()
+	s.mu.Lock()
+	if s.tokenTimer != nil {
+		s.tokenTimer.Stop()
+	}
+	s.mu.Unlock()
+	closeErr := c.close()
+	if writeErr != nil {
+		return writeErr
+	}
+	if err == nil {
+		err = closeErr
+	}
+	return
 }
 
 // AddSymbol ...
 func (s *Socket) AddSymbol(symbol string) (err error) {
+	s.trackSymbol(symbol)
 	err = s.sendSocketMessage(
 		getSocketMessage("quote_add_symbols", []any{s.quoteSessionID, symbol}),
 	)
 	if err != nil {
 		fmt.Printf("Error while sending quote_add_symbols: %v\n", err)
 	}
-	//m := getSocketMessage("quote_fast_symbols", []any{
-	//	s.quoteSessionID,
-	//	symbol,
-	//	`={"adjustment":"dividends","currency-id":"USD","session":"extended","settlement-as-close":false}`})
-	//err = s.sendSocketMessage(m)
-	//fmt.Printf("Error while sending quote_fast_symbols: %v\n", err)
 	return err
 }
 
-/*
-		`={"adjustment":"splits","currency-id":"USD","settlement-as-close":false,"symbol":"BATS:MSFT"}`,
-
-{
-  "m": "quote_fast_symbols",
-  "p": [
-    "qs_9oiruOcoLaJc",
-    "={\"adjustment\":\"splits\",\"currency-id\":\"USD\",\"settlement-as-close\":false,\"symbol\":\"BATS:MSFT\"}",
-    "NASDAQ:MSFT",
-    "={\"adjustment\":\"dividends\",\"currency-id\":\"USD\",\"session\":\"extended\",\"settlement-as-close\":false,\"symbol\":\"BATS:MSFT\"}"
-  ]
-}
-*/
-
-// RemoveSymbol ...
+// RemoveSymbol removes the symbol from the quote session, and from the symbols marked fast
 func (s *Socket) RemoveSymbol(symbol string) (err error) {
+	s.untrackSymbol(symbol)
+	s.mu.Lock()
+	s.fastSymbols = removeSymbol(s.fastSymbols, symbol)
+	s.mu.Unlock()
+	s.snapshots.remove(symbol)
 	err = s.sendSocketMessage(
 		getSocketMessage("quote_remove_symbols", []any{s.quoteSessionID, symbol}),
 	)
 	return
 }
 
-func (s *Socket) RequestQuotes(symbol string, bars int, interval string, onReceiveQuote OnReceiveQuoteCallback) (err error) {
-	s.OnReceiveQuoteCallback = onReceiveQuote
-	// 1. Add symbol
-	//send_message(ws, "quote_add_symbols", [websocket_session, symbol, {"flags": ["force_permission"]}], )
-	err = s.sendSocketMessage(
-		getSocketMessage("quote_add_symbols", []any{
-			s.quoteSessionID,
-			symbol,
-		}))
-	if err != nil {
-		return err
+// RequestQuotesContext requests the bars like RequestQuotes and waits until the series is completed.
+// Called from a callback it fails with ErrCalledFromCallback.
+func (s *Socket) RequestQuotesContext(ctx context.Context, symbol string, bars int, interval string, onReceiveQuote OnReceiveQuoteCallback) (err error) {
+	if err = ctx.Err(); err != nil {
+		return
 	}
-	// 2. Resolve symbol
-	m := getSocketMessage("resolve_symbol", []any{
-		s.chartSessionID,
-		"symbol_1",
-		`={"symbol": "` + symbol + `"}`})
-	err = s.sendSocketMessage(m)
-	if err != nil {
-		return err
+	if err = s.checkNotInLoop(); err != nil {
+		return
 	}
-	// single sync operation for now
-	s.Symbol = symbol
+	if interval, err = wireInterval(interval); err != nil {
+		return
+	}
+	if err = s.addChartSymbol(symbol); err != nil {
+		return
+	}
+	_, err = s.CreateSeriesContext(ctx, symbol, bars, interval, onReceiveQuote)
+	return
+}
 
-	// 3. Create series (NB: works for ADD but not for stocks)
-	err = s.sendSocketMessage(
-		getSocketMessage("create_series", []any{
-			s.chartSessionID,
-			//s.chartSessionName,
-			"price", "ask",
-			//s.chartSessionName,
-			"symbol_1",
-			interval,
-			bars,
-		}))
+// RequestQuotes adds the symbol to the quote session and creates a new chart series for it, see CreateSeries
+func (s *Socket) RequestQuotes(symbol string, bars int, interval string, onReceiveQuote OnReceiveQuoteCallback) (err error) {
+	if interval, err = wireInterval(interval); err != nil {
+		return
+	}
+	if err = s.addChartSymbol(symbol); err != nil {
+		return
+	}
+	_, err = s.CreateSeries(symbol, bars, interval, onReceiveQuote)
+	return
+}
 
-	return err
+func (s *Socket) addChartSymbol(symbol string) (err error) {
+	//send_message(ws, "quote_add_symbols", [websocket_session, symbol, {"flags": ["force_permission"]}], )
+	if err = s.AddSymbol(symbol); err != nil {
+		return
+	}
+	s.mu.Lock()
+	s.Symbol = symbol
+	s.mu.Unlock()
+	return
 }
 
-func (s *Socket) checkFirstReceivedMessage() (err error) {
+func (s *Socket) checkFirstReceivedMessage(c *connection) (err error) {
 	var msg []byte
 	//fmt.Printf("checkFirstReceivedMessage\n")
-	_, msg, err = s.conn.ReadMessage()
+	_, msg, err = c.read()
 	if err != nil {
-		s.onError(err, ReadFirstMessageErrorContext)
-		return
+		return newError(ErrHandshake, ReadFirstMessageErrorContext, err)
+	}
+	msgs, err := protocol.Decode(msg)
+	if err != nil {
+		return newError(ErrHandshake, DecodeFirstMessageErrorContext, err)
 	}
-	payload := msg[getPayloadStartingIndex(msg):]
-	//fmt.Printf("payload %s\n", string(payload))
 	var p map[string]any
 
-	err = json.Unmarshal(payload, &p)
+	err = json.Unmarshal(msgs[0].Payload, &p)
 	if err != nil {
-		s.onError(err, DecodeFirstMessageErrorContext)
-		return
+		return newError(ErrHandshake, DecodeFirstMessageErrorContext, err)
 	}
 
 	if p["session_id"] == nil {
 		err = errors.New("cannot recognize the first received message after establishing the connection")
-		s.onError(err, FirstMessageWithoutSessionIdErrorContext)
-		return
+		return newError(ErrHandshake, FirstMessageWithoutSessionIdErrorContext, err)
 	}
 
 	return
@@ -202,14 +334,14 @@ func (s *Socket) generateSessionID(isQuoteSession bool) string {
 	return x
 }
 
-func (s *Socket) sendConnectionSetupMessages(fields ...string) (err error) {
+func (s *Socket) sendConnectionSetupMessages(c *connection, token string, fields ...string) (err error) {
 	messages := []*SocketMessage{
-		getSocketMessage("set_auth_token", []string{"unauthorized_user_token"}),
+		getSocketMessage("set_auth_token", []string{token}),
 		getSocketMessage("chart_create_session", []string{s.chartSessionID, ""}),
 		getSocketMessage("quote_create_session", []string{s.quoteSessionID}),
 	}
 	for _, msg := range messages {
-		err = s.sendSocketMessage(msg)
+		err = s.sendSocketMessageTo(c, msg)
 		if err != nil {
 			return
 		}
@@ -225,244 +357,355 @@ func (s *Socket) sendConnectionSetupMessages(fields ...string) (err error) {
 	}
 	msg := getSocketMessage("quote_set_fields", m)
 	//fmt.Printf("send %s\n", msg)
-	_ = s.sendSocketMessage(msg)
+	_ = s.sendSocketMessageTo(c, msg)
 	return
 }
 
 func (s *Socket) sendSocketMessage(p *SocketMessage) (err error) {
+	c := s.connection()
+	if c == nil {
+		return newError(ErrConnectionClosed, SendMessageErrorContext, nil)
+	}
+	err = s.sendSocketMessageTo(c, p)
+	if err != nil {
+		s.onConnectionError(c, err, SendMessageErrorContext+" - "+p.Message)
+	}
+	return
+}
+
+// sendSocketMessageTo writes the message without reporting the error
+func (s *Socket) sendSocketMessageTo(c *connection, p *SocketMessage) (err error) {
 	payload, _ := json.Marshal(p)
-	payloadWithHeader := "~m~" + strconv.Itoa(len(payload)) + "~m~" + string(payload)
-	//fmt.Printf("Sending %s\n", payloadWithHeader)
-	err = s.conn.WriteMessage(websocket.TextMessage, []byte(payloadWithHeader))
+	err = c.write(websocket.TextMessage, protocol.Encode(protocol.Message{Payload: payload}))
 	if err != nil {
-		s.onError(err, SendMessageErrorContext+" - "+payloadWithHeader)
-		return
+		return newError(ErrConnectionClosed, SendMessageErrorContext, err)
 	}
 	return
 }
 
 func (s *Socket) connectionLoop() {
-	var readMsgError error
-	var writeKeepAliveMsgError error
-
-	for readMsgError == nil && writeKeepAliveMsgError == nil {
-		if s.isClosed {
-			break
+	s.connectionGoroutine.Store(goroutineID())
+	defer close(s.loopDone)
+	for {
+		c := s.connection()
+		context, err := s.readLoop(c)
+		if s.closed() {
+			return
 		}
+		if s.Reconnect == nil {
+			s.onConnectionError(c, newError(ErrConnectionClosed, context, err), context)
+			s.shutdown()
+			return
+		}
+		if err = s.reconnect(err); err != nil {
+			s.onError(err, ReconnectErrorContext)
+			s.shutdown()
+			return
+		}
+	}
+}
+
+// shutdown closes the socket once the connection is lost for good, without reconnect or after reconnect gave up.
+// The pending calls fail with ErrConnectionClosed, the streams are closed and the dispatch loop ends.
+func (s *Socket) shutdown() {
+	s.mu.Lock()
+	s.isClosed = true
+	if s.tokenTimer != nil {
+		s.tokenTimer.Stop()
+	}
+	s.mu.Unlock()
+	s.cancel()
+}
 
+// readLoop is the only reader of the connection. Keep-alive messages are answered right away,
+// everything else is handed over to the dispatch loop so the callbacks run in arrival order.
+func (s *Socket) readLoop(c *connection) (context string, err error) {
+	for {
 		var msgType int
 		var msg []byte
 
-		msgType, msg, readMsgError = s.conn.ReadMessage()
-		//fmt.Printf("ReadMessage - Received msg type %d, payload: %s, err %v\n", msgType, string(msg), readMsgError)
-		go func(msgType int, msg []byte) {
-			if msgType != websocket.TextMessage {
-				return
-			}
+		msgType, msg, err = c.read()
+		//fmt.Printf("ReadMessage - Received msg type %d, payload: %s, err %v\n", msgType, string(msg), err)
+		if err != nil {
+			return ReadMessageErrorContext, err
+		}
+		if msgType != websocket.TextMessage {
+			continue
+		}
 
-			if isKeepAliveMsg(msg) {
-				writeKeepAliveMsgError = s.conn.WriteMessage(msgType, msg)
-				return
+		if isKeepAliveMsg(msg) {
+			if err = c.write(msgType, msg); err != nil {
+				return SendKeepAliveMessageErrorContext, err
 			}
+			continue
+		}
 
-			go s.parsePacket(msg)
-		}(msgType, msg)
+		select {
+		case s.inbox <- msg:
+		case <-s.ctx.Done():
+			return ReadMessageErrorContext, s.ctx.Err()
+		}
 	}
+}
 
-	if readMsgError != nil {
-		s.onError(readMsgError, ReadMessageErrorContext)
-	}
-	if writeKeepAliveMsgError != nil {
-		s.onError(writeKeepAliveMsgError, SendKeepAliveMessageErrorContext)
+// dispatchLoop parses the received packets one at a time, in the order they were read
+func (s *Socket) dispatchLoop() {
+	s.dispatchGoroutine.Store(goroutineID())
+	for {
+		select {
+		case msg := <-s.inbox:
+			s.safely(func() { s.parsePacket(msg) })
+			if s.dispatched != nil {
+				s.dispatched()
+			}
+		case <-s.ctx.Done():
+			return
+		}
 	}
 }
 
+func (s *Socket) closed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.isClosed
+}
+
 func (s *Socket) parsePacket(packet []byte) {
-	var symbolsArr []string
-	var dataArr []*QuoteData
+	var quotes quoteBatch
 
-	index := 0
-	for index < len(packet) {
-		payloadLength, err := getPayloadLength(packet[index:])
-		if err != nil {
-			fmt.Printf("Error while getting payload length - %v\n", err)
-			s.onError(err, GetPayloadLengthErrorContext+" - "+string(packet))
-			return
+	msgs, err := protocol.Decode(packet)
+	if err != nil {
+		s.onError(newError(ErrProtocol, GetPayloadLengthErrorContext, err), GetPayloadLengthErrorContext+" - "+string(packet))
+	}
+	for _, msg := range msgs {
+		if msg.IsHeartbeat() {
+			continue
 		}
-
-		headerLength := 6 + len(strconv.Itoa(payloadLength))
-		payload := packet[index+headerLength : index+headerLength+payloadLength]
-		index = index + headerLength + len(payload)
-		//fmt.Printf("> Payload %s\n", payload)
-
-		symbol, data, err := s.parseJSON(payload)
+		symbol, data, err := s.parseJSON(msg.Payload)
 		if err != nil {
 			fmt.Printf("> parseJSON error %s\n", err.Error())
 			continue
 		}
-		hloc, ok := data.([]TOHLCV)
-		if ok {
-			//fmt.Printf(">>> Received %s - %+v\n", symbol, x)
-			if s.OnReceiveQuoteCallback != nil {
-				s.OnReceiveQuoteCallback(s.Symbol, hloc)
+		if update, ok := data.(*timescaleUpdate); ok {
+			for _, id := range update.ids {
+				if hloc, ok := update.series[id]; ok {
+					s.safely(func() { s.onSeriesBars(id, update.turnarounds[id], hloc, update.indexes[id]) })
+				}
+				if rows, ok := update.studies[id]; ok {
+					s.safely(func() { s.onStudyRows(id, rows) })
+				}
 			}
 			continue
 		}
-		//fmt.Printf(">>> Received %s - %+v\n", symbol, data)
-		if data == nil {
+		if status, ok := data.(*seriesStatus); ok {
+			s.safely(func() { s.onSeriesStatus(status) })
 			continue
 		}
-		dataArr = append(dataArr, data.(*QuoteData))
-		symbolsArr = append(symbolsArr, symbol)
+		if resolved, ok := data.(*symbolResolved); ok {
+			s.safely(func() { s.onSymbolResolved(resolved) })
+			continue
+		}
+		//fmt.Printf(">>> Received %s - %+v\n", symbol, data)
+		if quote, ok := data.(*QuoteData); ok && quote != nil {
+			quotes.add(symbol, quote)
+		}
 	}
-	if s.OnReceiveMarketDataCallback == nil {
+	for _, q := range quotes.updates {
+		s.safely(func() { s.onQuote(q.symbol, q.data) })
+	}
+}
+
+// safely runs a step of the dispatch of received messages, a panic in it or in the callbacks it calls
+// is reported as a *PanicError and the next messages are still dispatched
+func (s *Socket) safely(step func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.onPanic(&PanicError{Value: r, Stack: debug.Stack()})
+		}
+	}()
+	step()
+}
+
+// onPanic reports the panic without closing the connection
+func (s *Socket) onPanic(panicErr *PanicError) {
+	err := newError(ErrPanic, DispatchMessageErrorContext, panicErr)
+	if s.OnErrorCallback == nil {
+		fmt.Printf("%v\n%s", err, panicErr.Stack)
 		return
 	}
-	// TODO: fix this nested loop !!!
-	for i := 0; i < len(dataArr); i++ {
-		isDuplicate := false
-		for j := i + 1; j < len(dataArr); j++ {
-			if GetStringRepresentation(dataArr[i]) == GetStringRepresentation(dataArr[j]) {
-				isDuplicate = true
-				break
-			}
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Printf("%v\npanic in OnErrorCallback: %v\n", err, r)
 		}
-		if !isDuplicate {
-			s.OnReceiveMarketDataCallback(symbolsArr[i], dataArr[i])
+	}()
+	s.OnErrorCallback(err, DispatchMessageErrorContext)
+}
+
+// quoteBatch coalesces the qsd of a packet: the updates of a symbol are merged into its first one,
+// the later values winning, and the symbols keep the order of their first update
+type quoteBatch struct {
+	updates []quoteUpdate
+	// index maps the symbols to their update, built once the packet holds more than one quote
+	index map[string]int
+}
+
+type quoteUpdate struct {
+	symbol string
+	data   *QuoteData
+}
+
+func (b *quoteBatch) add(symbol string, data *QuoteData) {
+	if len(b.updates) == 1 && b.index == nil {
+		b.index = map[string]int{b.updates[0].symbol: 0}
+	}
+	if b.index != nil {
+		if i, ok := b.index[symbol]; ok {
+			b.updates[i].data.Merge(data)
+			return
 		}
+		b.index[symbol] = len(b.updates)
 	}
+	b.updates = append(b.updates, quoteUpdate{symbol: symbol, data: data})
 }
 
 func (s *Socket) parseJSON(payload []byte) (symbol string, data any, err error) {
-	var msg *SocketMessage
+	name, p, err := decodeEnvelope(payload)
+	if err != nil {
+		err = newError(ErrProtocol, DecodeMessageErrorContext, err)
+		s.onError(err, DecodeMessageErrorContext+" - "+string(payload))
+		return
+	}
+
+	// the frequent messages skip the generic decoding
+	switch name {
+	case "timescale_update", "du":
+		data, err = parseTimeScaleUpdate(p)
+		return
+	case "qsd":
+		return s.parseQSD(payload, p)
+	}
 
+	var msg *SocketMessage
 	err = json.Unmarshal(payload, &msg)
 	if err != nil {
+		err = newError(ErrProtocol, DecodeMessageErrorContext, err)
 		s.onError(err, DecodeMessageErrorContext+" - "+string(payload))
 		return
 	}
 
-	if msg.Message == "critical_error" || msg.Message == "error" {
-		err = errors.New("Error -> " + string(payload))
+	if msg.Message == "critical_error" || msg.Message == "error" || msg.Message == "protocol_error" {
+		serverErr := &ServerError{Type: msg.Message}
+		serverErr.Payload, _ = msg.Payload.([]any)
+		err = newError(ErrServerError, DecodedMessageHasErrorPropertyErrorContext, serverErr)
 		s.onError(err, DecodedMessageHasErrorPropertyErrorContext)
 		return
 	}
 
-	if msg.Message == "timescale_update" {
-		return parseTimeScaleUpdate(payload)
+	if msg.Message == "symbol_resolved" {
+		data, err = parseSymbolResolved(msg)
+		if err != nil {
+			err = newError(ErrProtocol, FinalPayloadCantBeParsedErrorContext, err)
+			s.onError(err, FinalPayloadCantBeParsedErrorContext+" - "+string(payload))
+		}
+		return
 	}
 
-	if msg.Message != "qsd" {
-		//err = errors.New("ignored message (Not qsd), got: " + msg.Message)
+	switch msg.Message {
+	case "series_completed", "series_error", "symbol_error", "study_completed", "study_error":
+		data, err = parseSeriesStatus(msg)
 		return
 	}
 
-	if msg.Payload == nil {
-		err = errors.New("Msg does not include 'p' -> " + string(payload))
+	//err = errors.New("ignored message (Not qsd), got: " + msg.Message)
+	return
+}
+
+// parseQSD returns the symbol and the quote data of a qsd message, p is its raw payload
+func (s *Socket) parseQSD(payload, p []byte) (symbol string, data *QuoteData, err error) {
+	if p == nil {
+		err = newError(ErrProtocol, DecodedMessageDoesNotIncludePayloadErrorContext, errors.New("Msg does not include 'p' -> "+string(payload)))
 		s.onError(err, DecodedMessageDoesNotIncludePayloadErrorContext)
 		return
 	}
-	p, isPOk := msg.Payload.([]any)
-	if !isPOk {
-		fmt.Printf("expected array\n")
-		err = errors.New("There is something wrong with the payload - can't be parsed -> " + string(payload))
-		fmt.Printf("err: %v\n", err)
-		//s.onError(err, PayloadCantBeParsedErrorContext)
-		return
-	}
-
-	if len(p) != 2 {
-		fmt.Printf("expected array with len 2 got %d\n", len(p))
+	quote, ok := splitQSD(p)
+	if !ok {
 		err = errors.New("There is something wrong with the payload - can't be parsed -> " + string(payload))
 		fmt.Printf("err: %v\n", err)
 		//s.onError(err, PayloadCantBeParsedErrorContext)
 		return
 	}
 
-	var decodedQuoteMessage *QuoteMessage
-	err = mapstructure.Decode(p[1].(map[string]any), &decodedQuoteMessage)
+	decodedQuoteMessage, err := parseQuoteMessage(quote)
 	if err != nil {
+		err = newError(ErrProtocol, FinalPayloadCantBeParsedErrorContext, err)
 		s.onError(err, FinalPayloadCantBeParsedErrorContext+" - "+string(payload))
 		return
 	}
 
 	if decodedQuoteMessage.Status != "ok" || decodedQuoteMessage.Symbol == "" || decodedQuoteMessage.Data == nil {
 		err = errors.New("There is something wrong with the payload - couldn't be parsed -> " + string(payload))
+		err = newError(ErrProtocol, FinalPayloadHasMissingPropertiesErrorContext, err)
 		s.onError(err, FinalPayloadHasMissingPropertiesErrorContext)
 		return
 	}
-	symbol = decodedQuoteMessage.Symbol
-	data = decodedQuoteMessage.Data
-	return symbol, data, nil
+	return decodedQuoteMessage.Symbol, decodedQuoteMessage.Data, nil
 }
 
-func parseTimeScaleUpdate(payload []byte) (sym string, hloc []TOHLCV, err error) {
-	d := make(map[string]any)
-	err = json.Unmarshal(payload, &d)
-	if err != nil {
-		return
-	}
-	err = fmt.Errorf("parsing error")
-	//fmt.Printf("d: %+v\n", d)
-	p := d["p"].([]any)
-	if len(p) < 2 {
-		return
+func (s *Socket) onQuote(symbol string, data *QuoteData) {
+	s.snapshots.merge(symbol, data)
+	if s.OnReceiveSnapshotCallback != nil {
+		if snapshot, ok := s.snapshots.get(symbol); ok {
+			s.OnReceiveSnapshotCallback(snapshot)
+		}
 	}
-	price := p[1]
-	var amap map[string]any
-	var a any
-	if amap = price.(map[string]any); amap == nil {
-		return
+	if s.OnReceiveMarketDataCallback != nil {
+		s.OnReceiveMarketDataCallback(symbol, data)
 	}
-	if a = amap["price"]; a == nil {
+	s.publishQuote(symbol, data)
+}
+
+// parseSeriesStatus decodes series_completed, series_error, symbol_error, study_completed and study_error messages
+func parseSeriesStatus(msg *SocketMessage) (status *seriesStatus, err error) {
+	p, ok := msg.Payload.([]any)
+	if !ok || len(p) < 2 {
+		err = errors.New("There is something wrong with the " + msg.Message + " payload")
 		return
 	}
-	if a = a.(map[string]any)["s"]; a == nil {
+	id, _ := p[1].(string)
+	status = &seriesStatus{seriesID: id}
+	if msg.Message == "series_completed" || msg.Message == "study_completed" {
+		if len(p) > 3 {
+			status.turnaround, _ = p[3].(string)
+		}
 		return
 	}
-
-	hloc = make([]TOHLCV, 0)
-	for _, v := range a.([]any) {
-		amap = v.(map[string]any)
-		//i := int((amap["i"]).(float64))
-		vals := (amap["v"]).([]any)
-		var h TOHLCV
-		for j, val := range vals {
-			switch j {
-			case 0:
-				h.Time = int64(val.(float64))
-			case 1:
-				h.Open = val.(float64)
-			case 2:
-				h.High = val.(float64)
-			case 3:
-				h.Low = val.(float64)
-			case 4:
-				h.Close = val.(float64)
-			case 5:
-				h.Volume = int64(val.(float64))
-			default:
-			}
-		}
-		//fmt.Printf("h: %+v\n", h)
-		hloc = append(hloc, h)
+	seriesErr := &SeriesError{Type: msg.Message, Message: msg.Message}
+	if reason, ok := p[len(p)-1].(string); ok && len(p) > 2 {
+		seriesErr.Message = reason
 	}
-	return sym, hloc, nil
+	status.err = seriesErr
+	return
 }
 
+// onError reports the error, unless the socket has been closed by the caller.
+// The errors of a single message, e.g. a rejected symbol or a malformed frame, leave the connection open.
 func (s *Socket) onError(err error, context string) {
-	if strings.Contains(err.Error(), "closed") {
+	if s.ctx != nil && s.ctx.Err() != nil {
 		return
 	}
 	//fmt.Printf("ONERROR Error: %v\n", err)
-	if s.conn != nil {
-		_ = s.conn.Close()
-	}
 	if s.OnErrorCallback != nil {
 		s.OnErrorCallback(err, context)
 	}
 }
 
+// onConnectionError closes the connection that failed and reports the error.
+// The connection loop then reconnects, a connection already replaced by a reconnect is closed alone.
+func (s *Socket) onConnectionError(c *connection, err error, context string) {
+	_ = c.close()
+	s.onError(err, context)
+}
+
 func getSocketMessage(m string, p any) *SocketMessage {
 	return &SocketMessage{
 		Message: m,
@@ -476,34 +719,18 @@ func getFlags() *Flags {
 	}
 }
 
+// isKeepAliveMsg reports whether the frame holds heartbeats only
 func isKeepAliveMsg(msg []byte) bool {
-	return string(msg[getPayloadStartingIndex(msg)]) == "~"
-}
-
-func getPayloadStartingIndex(msg []byte) int {
-	char := ""
-	index := 3
-	for char != "~" {
-		char = string(msg[index])
-		index++
+	msgs, err := protocol.Decode(msg)
+	if err != nil {
+		return false
 	}
-	index += 2
-	return index
-}
-
-func getPayloadLength(msg []byte) (length int, err error) {
-	char := ""
-	index := 3
-	lengthAsString := ""
-	for char != "~" {
-		char = string(msg[index])
-		if char != "~" {
-			lengthAsString += char
+	for _, m := range msgs {
+		if !m.IsHeartbeat() {
+			return false
 		}
-		index++
 	}
-	length, err = strconv.Atoi(lengthAsString)
-	return
+	return true
 }
 
 func getHeaders() http.Header {
@@ -512,7 +739,6 @@ func getHeaders() http.Header {
 	headers.Set("Accept-Encoding", "gzip, deflate, br")
 	headers.Set("Accept-Language", "en-US,en;q=0.9,es;q=0.8")
 	headers.Set("Cache-Control", "no-cache")
-	headers.Set("Host", "data.tradingview.com")
 	headers.Set("Origin", "https://www.tradingview.com")
 	headers.Set("Pragma", "no-cache")
 	headers.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/86.0.4240.193 Safari/537.36")
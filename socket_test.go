@@ -3,13 +3,22 @@ package tvsocket
 import (
 	"fmt"
 	"github.com/stretchr/testify/require"
+	"os"
 	"strings"
 	"time"
 
 	"testing"
 )
 
+// skipUnlessLive skips the tests that dial the real TradingView socket unless TVSOCKET_LIVE is set
+func skipUnlessLive(t *testing.T) {
+	if os.Getenv("TVSOCKET_LIVE") == "" {
+		t.Skip("set TVSOCKET_LIVE=1 to run the tests against data.tradingview.com")
+	}
+}
+
 func TestSocket_RequestQuotesADD(t *testing.T) {
+	skipUnlessLive(t)
 	var quote QuoteData
 	symbol := "USI:ADD"
 	//fields := []string{"close_price", "open_price", "high_price", "low_price"}
@@ -38,6 +47,7 @@ func TestSocket_RequestQuotesADD(t *testing.T) {
 }
 
 func TestSocket_RequestQuotesSTOCK(t *testing.T) {
+	skipUnlessLive(t)
 	var quote QuoteData
 	symbol := "NASDAQ:NVDA"
 	//fields := []string{"close_price", "open_price", "high_price", "low_price"}
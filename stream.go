@@ -0,0 +1,204 @@
+package tvsocket
+
+import (
+	"context"
+	"sync"
+)
+
+// OverflowPolicy decides what happens when the buffer of a stream is full
+type OverflowPolicy int
+
+const (
+	// OverflowBlock waits for the consumer. While waiting no other message of the socket is dispatched.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDrop discards the new event
+	OverflowDrop
+)
+
+const (
+	defaultStreamBuffer  = 64
+	defaultStreamHistory = 300
+)
+
+// QuoteEvent is delivered by Subscribe
+type QuoteEvent struct {
+	Symbol string
+	Data   *QuoteData
+}
+
+// BarEvent is delivered by StreamBars, StreamBarEvents and OnBarEventCallback
+type BarEvent struct {
+	Symbol   string
+	Interval string
+	// Bars holds the bars of an update for the BarBatch events of StreamBars
+	Bars []TOHLCV
+	// Kind, Index and Bar describe the change of one bar, identified by its index in the series
+	Kind  BarEventKind
+	Index int
+	Bar   TOHLCV
+}
+
+// StudyEvent is delivered by StreamStudy
+type StudyEvent struct {
+	StudyID string
+	Rows    []StudyRow
+}
+
+// stream is a channel that can be closed while a publisher is blocked on it
+type stream[T any] struct {
+	ch       chan T
+	done     chan struct{}
+	doneOnce sync.Once
+	mu       sync.Mutex
+	closed   bool
+	policy   OverflowPolicy
+}
+
+func newStream[T any](c *config) *stream[T] {
+	size := c.streamBuffer
+	if size <= 0 {
+		size = defaultStreamBuffer
+	}
+	return &stream[T]{
+		ch:     make(chan T, size),
+		done:   make(chan struct{}),
+		policy: c.overflowPolicy,
+	}
+}
+
+func (st *stream[T]) publish(v T) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.closed {
+		return
+	}
+	if st.policy == OverflowDrop {
+		select {
+		case st.ch <- v:
+		default:
+		}
+		return
+	}
+	select {
+	case st.ch <- v:
+	case <-st.done:
+	}
+}
+
+func (st *stream[T]) close() {
+	// unblocks a pending publish before taking the lock
+	st.doneOnce.Do(func() {
+		close(st.done)
+	})
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if !st.closed {
+		st.closed = true
+		close(st.ch)
+	}
+}
+
+// closeWhenDone closes the stream once ctx or the socket is done, then runs cleanup.
+// The socket is done when it is closed or its connection is lost for good, see shutdown.
+func (s *Socket) closeWhenDone(ctx context.Context, close func(), cleanup func()) {
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-s.ctx.Done():
+		}
+		cleanup()
+		close()
+	}()
+}
+
+// quoteStream is a Subscribe channel filtered by symbol, an empty filter matches every symbol
+type quoteStream struct {
+	*stream[QuoteEvent]
+	symbols map[string]bool
+}
+
+// Subscribe adds the symbols to the quote session and returns a channel with their updates.
+// Without symbols the channel receives the updates of every symbol of the session.
+// The channel is closed when ctx is done or the socket is closed; the symbols stay in the quote session.
+func (s *Socket) Subscribe(ctx context.Context, symbols ...string) (<-chan QuoteEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	qs := &quoteStream{
+		stream:  newStream[QuoteEvent](&s.config),
+		symbols: make(map[string]bool),
+	}
+	for _, symbol := range symbols {
+		qs.symbols[symbol] = true
+	}
+	s.mu.Lock()
+	s.quoteStreams = append(s.quoteStreams, qs)
+	s.mu.Unlock()
+	s.closeWhenDone(ctx, qs.close, func() {
+		s.removeQuoteStream(qs)
+	})
+
+	if len(symbols) > 0 {
+		p := []any{s.quoteSessionID}
+		for _, symbol := range symbols {
+			s.trackSymbol(symbol)
+			p = append(p, symbol)
+		}
+		if err := s.sendSocketMessage(getSocketMessage("quote_add_symbols", p)); err != nil {
+			s.removeQuoteStream(qs)
+			qs.close()
+			return nil, err
+		}
+	}
+	return qs.ch, nil
+}
+
+// StreamBars creates a series for the symbol and returns a channel with its history and updates.
+// The channel is closed and the series removed when ctx is done or the socket is closed.
+func (s *Socket) StreamBars(ctx context.Context, symbol string, interval string) (<-chan BarEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	interval, err := wireInterval(interval)
+	if err != nil {
+		return nil, err
+	}
+	bars := s.config.streamHistory
+	if bars <= 0 {
+		bars = defaultStreamHistory
+	}
+	st := newStream[BarEvent](&s.config)
+	series, err := s.CreateSeries(symbol, bars, interval, func(symbol string, hloc []TOHLCV) {
+		st.publish(BarEvent{Symbol: symbol, Interval: interval, Bars: hloc})
+	})
+	if err != nil {
+		st.close()
+		return nil, err
+	}
+	s.closeWhenDone(ctx, st.close, func() {
+		_ = series.Remove()
+	})
+	return st.ch, nil
+}
+
+func (s *Socket) removeQuoteStream(qs *quoteStream) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, v := range s.quoteStreams {
+		if v == qs {
+			s.quoteStreams = append(s.quoteStreams[:i], s.quoteStreams[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *Socket) publishQuote(symbol string, data *QuoteData) {
+	s.mu.Lock()
+	streams := append([]*quoteStream(nil), s.quoteStreams...)
+	s.mu.Unlock()
+	for _, qs := range streams {
+		if len(qs.symbols) == 0 || qs.symbols[symbol] {
+			qs.publish(QuoteEvent{Symbol: symbol, Data: data})
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package tvsocket
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ivo100/tvsocket/tvtest"
+	"github.com/stretchr/testify/require"
+)
+
+func qsd(symbol string, price float64) string {
+	return fmt.Sprintf(`{"m":"qsd","p":["qs_x",{"n":"%s","s":"ok","v":{"lp":%v}}]}`, symbol, price)
+}
+
+func TestSocket_Subscribe(t *testing.T) {
+	server := tvtest.NewServer(t)
+	tv, err := ConnectWithOptions(context.Background(), WithURL(server.URL()))
+	require.NoError(t, err)
+	defer tv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	quotes, err := tv.Subscribe(ctx, "NASDAQ:MSFT")
+	require.NoError(t, err)
+	all, err := tv.Subscribe(context.Background())
+	require.NoError(t, err)
+	m := server.WaitFor(t, "quote_add_symbols")
+	require.Equal(t, []any{tv.quoteSessionID, "NASDAQ:MSFT"}, m.Payload)
+
+	server.Send(qsd("NASDAQ:AAPL", 190))
+	server.Send(qsd("NASDAQ:MSFT", 420))
+
+	event := <-quotes
+	require.Equal(t, "NASDAQ:MSFT", event.Symbol)
+	require.Equal(t, 420.0, *event.Data.Price)
+	require.Equal(t, "NASDAQ:AAPL", (<-all).Symbol)
+	require.Equal(t, "NASDAQ:MSFT", (<-all).Symbol)
+
+	cancel()
+	_, ok := <-quotes
+	require.False(t, ok)
+
+	require.NoError(t, tv.Close())
+	_, ok = <-all
+	require.False(t, ok)
+}
+
+func TestSocket_SubscribeConnectionLost(t *testing.T) {
+	server := tvtest.NewServer(t)
+	tv, err := ConnectWithOptions(context.Background(), WithURL(server.URL()))
+	require.NoError(t, err)
+	defer tv.Close()
+
+	quotes, err := tv.Subscribe(context.Background(), "NASDAQ:MSFT")
+	require.NoError(t, err)
+	server.WaitFor(t, "quote_add_symbols")
+
+	server.Disconnect()
+	done := make(chan struct{})
+	go func() {
+		for range quotes {
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("channel was not closed after the connection was lost")
+	}
+}
+
+func TestSocket_SubscribeDropPolicy(t *testing.T) {
+	server := tvtest.NewServer(t)
+	received := make(chan struct{})
+	tv, err := ConnectWithOptions(context.Background(),
+		WithURL(server.URL()),
+		WithStreamBuffer(1),
+		WithOverflowPolicy(OverflowDrop),
+		WithOnReceiveData(func(symbol string, data *QuoteData) {
+			if *data.Price == 3 {
+				close(received)
+			}
+		}),
+	)
+	require.NoError(t, err)
+	defer tv.Close()
+
+	quotes, err := tv.Subscribe(context.Background(), "NASDAQ:MSFT")
+	require.NoError(t, err)
+	server.Send(qsd("NASDAQ:MSFT", 1))
+	server.Send(qsd("NASDAQ:MSFT", 2))
+	server.Send(qsd("NASDAQ:MSFT", 3))
+	<-received
+
+	require.Equal(t, 1.0, *(<-quotes).Data.Price)
+	select {
+	case event := <-quotes:
+		t.Fatalf("unexpected event %v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSocket_StreamBars(t *testing.T) {
+	server := tvtest.NewServer(t)
+	tv, err := ConnectWithOptions(context.Background(), WithURL(server.URL()), WithStreamHistory(10))
+	require.NoError(t, err)
+	defer tv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	bars, err := tv.StreamBars(ctx, "NASDAQ:MSFT", "5")
+	require.NoError(t, err)
+	m := server.WaitFor(t, "create_series")
+	require.Equal(t, float64(10), m.Payload[5])
+
+	server.Send(`{"m":"timescale_update","p":["cs_x",{"sds_1":{"s":[{"i":0,"v":[1716290100.0,1,2,0.5,1.5,100]}]}}]}`)
+	event := <-bars
+	require.Equal(t, "NASDAQ:MSFT", event.Symbol)
+	require.Equal(t, "5", event.Interval)
+	require.Equal(t, 1.5, event.Bars[0].Close)
+
+	cancel()
+	_, ok := <-bars
+	require.False(t, ok)
+	m = server.WaitFor(t, "remove_series")
+	require.Equal(t, "sds_1", m.Payload[1])
+}
@@ -0,0 +1,224 @@
+package tvsocket
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// studyNoValue is sent in place of a plot value the study has not computed for the bar
+const studyNoValue = 1e100
+
+// StudyRow holds the plot values of a study at a bar of its series
+type StudyRow struct {
+	// Index is the index of the bar in the series
+	Index int
+	Time  int64
+	// Values are the plots in the order the study declares them, NaN when a plot has no value at the bar
+	Values []float64
+}
+
+// Value returns the i-th plot value of the row, NaN when the plot is missing
+func (r StudyRow) Value(i int) float64 {
+	if i < 0 || i >= len(r.Values) {
+		return math.NaN()
+	}
+	return r.Values[i]
+}
+
+// Study is an indicator computed by the server on a chart series, created by AddStudy
+type Study struct {
+	socket   *Socket
+	n        int
+	id       string
+	series   *Series
+	script   string
+	inputs   map[string]any
+	callback OnReceiveStudyCallback
+	waiters  waiters
+}
+
+// ID returns the study id used on the wire, e.g. st1
+func (st *Study) ID() string {
+	return st.id
+}
+
+// Script returns the study id given to AddStudy, e.g. Volume@tv-basicstudies-246
+func (st *Study) Script() string {
+	return st.script
+}
+
+// Series returns the series the study is computed on
+func (st *Study) Series() *Series {
+	return st.series
+}
+
+// AddStudy adds a study to the series, its rows are delivered to OnReceiveStudyCallback.
+// studyID names the study, e.g. Volume@tv-basicstudies-246, and inputs holds its parameters; a Pine script is
+// added as Script@tv-scripting-101! with its pineId and pineVersion among the inputs.
+func (s *Socket) AddStudy(series *Series, studyID string, inputs map[string]any) (study *Study, err error) {
+	study = s.newStudy(series, studyID, inputs, nil)
+	if err = s.sendStudy(study); err != nil {
+		_ = study.Remove()
+		return nil, err
+	}
+	return
+}
+
+// AddStudyContext adds a study like AddStudy and waits until the rows of the whole series have been delivered.
+// A study rejected by the server is reported as a *SeriesError.
+// Called from a callback it fails with ErrCalledFromCallback.
+func (s *Socket) AddStudyContext(ctx context.Context, series *Series, studyID string, inputs map[string]any) (study *Study, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	if err = s.checkNotInLoop(); err != nil {
+		return
+	}
+	study = s.newStudy(series, studyID, inputs, nil)
+	completed := s.addWaiter(&study.waiters)
+	if err = s.sendStudy(study); err == nil {
+		err = s.waitCompleted(ctx, &study.waiters, completed, StudyErrorContext)
+	}
+	if err != nil {
+		_ = study.Remove()
+		return nil, err
+	}
+	return
+}
+
+// StreamStudy adds a study to the series and returns a channel with its rows.
+// The channel is closed and the study removed when ctx is done or the socket is closed.
+func (s *Socket) StreamStudy(ctx context.Context, series *Series, studyID string, inputs map[string]any) (<-chan StudyEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	events := newStream[StudyEvent](&s.config)
+	study := s.newStudy(series, studyID, inputs, func(study *Study, rows []StudyRow) {
+		events.publish(StudyEvent{StudyID: study.id, Rows: rows})
+	})
+	if err := s.sendStudy(study); err != nil {
+		_ = study.Remove()
+		events.close()
+		return nil, err
+	}
+	s.closeWhenDone(ctx, events.close, func() {
+		_ = study.Remove()
+	})
+	return events.ch, nil
+}
+
+// Remove deletes the study from the chart session
+func (st *Study) Remove() (err error) {
+	s := st.socket
+	s.mu.Lock()
+	_, ok := s.studies[st.id]
+	delete(s.studies, st.id)
+	s.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	s.notifyWaiters(&st.waiters, errors.New("the study has been removed"))
+	return s.sendSocketMessage(getSocketMessage("remove_study", []any{s.chartSessionID, st.id}))
+}
+
+// newStudy allocates a unique study id and registers the study
+func (s *Socket) newStudy(series *Series, script string, inputs map[string]any, onReceive OnReceiveStudyCallback) *Study {
+	if inputs == nil {
+		inputs = map[string]any{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.studyCounter++
+	study := &Study{
+		socket:   s,
+		n:        s.studyCounter,
+		id:       "st" + strconv.Itoa(s.studyCounter),
+		series:   series,
+		script:   script,
+		inputs:   inputs,
+		callback: onReceive,
+	}
+	if s.studies == nil {
+		s.studies = make(map[string]*Study)
+	}
+	s.studies[study.id] = study
+	return study
+}
+
+// sendStudy creates the study on the server
+func (s *Socket) sendStudy(study *Study) error {
+	return s.sendSocketMessage(getSocketMessage("create_study", []any{
+		s.chartSessionID,
+		study.id,
+		"st1",
+		study.series.id,
+		study.script,
+		study.inputs,
+	}))
+}
+
+func (s *Socket) findStudy(id string) *Study {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.studies[id]
+}
+
+// activeStudies returns the registered studies in creation order
+func (s *Socket) activeStudies() []*Study {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	studies := make([]*Study, 0, len(s.studies))
+	for _, v := range s.studies {
+		studies = append(studies, v)
+	}
+	sort.Slice(studies, func(i, j int) bool {
+		return studies[i].n < studies[j].n
+	})
+	return studies
+}
+
+// dropStudies unregisters the studies of the series and ends their pending requests
+func (s *Socket) dropStudies(series *Series, err error) {
+	var dropped []*Study
+	s.mu.Lock()
+	for id, study := range s.studies {
+		if study.series == series {
+			delete(s.studies, id)
+			dropped = append(dropped, study)
+		}
+	}
+	s.mu.Unlock()
+	for _, study := range dropped {
+		s.notifyWaiters(&study.waiters, err)
+	}
+}
+
+func (s *Socket) onStudyRows(id string, rows []StudyRow) {
+	study := s.findStudy(id)
+	if study == nil {
+		return
+	}
+	callback := study.callback
+	if callback == nil {
+		callback = s.OnReceiveStudyCallback
+	}
+	if callback != nil {
+		callback(study, rows)
+	}
+}
+
+// onStudyStatus handles study_completed and study_error, it reports whether the id is a study
+func (s *Socket) onStudyStatus(status *seriesStatus) bool {
+	study := s.findStudy(status.seriesID)
+	if study == nil {
+		return false
+	}
+	if seriesErr, ok := status.err.(*SeriesError); ok {
+		seriesErr.Symbol = study.series.Symbol()
+	}
+	s.notifyWaiters(&study.waiters, status.err)
+	return true
+}
@@ -0,0 +1,197 @@
+package tvsocket
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ivo100/tvsocket/protocol"
+	"github.com/ivo100/tvsocket/tvtest"
+	"github.com/stretchr/testify/require"
+)
+
+const volumeStudy = "Volume@tv-basicstudies-246"
+
+func studyServer(t *testing.T) *tvtest.Server {
+	server := tvtest.NewServer(t)
+	server.SetBars("NASDAQ:MSFT",
+		tvtest.Bar{Time: 1716290100, Open: 1, High: 2, Low: 0.5, Close: 1.5, Volume: 100},
+		tvtest.Bar{Time: 1716290400, Open: 1.5, High: 3, Low: 1, Close: 2.5, Volume: 200},
+	)
+	server.SetStudy(volumeStudy,
+		tvtest.StudyRow{Time: 1716290100, Values: []float64{100, tvtest.NoValue, 0}},
+		tvtest.StudyRow{Time: 1716290400, Values: []float64{200, 150, 1}},
+	)
+	return server
+}
+
+func TestSocket_AddStudy(t *testing.T) {
+	server := studyServer(t)
+	received := make(chan []StudyRow, 1)
+	tv, err := ConnectWithOptions(context.Background(),
+		WithURL(server.URL()),
+		WithOnStudy(func(study *Study, rows []StudyRow) {
+			require.Equal(t, volumeStudy, study.Script())
+			received <- rows
+		}),
+	)
+	require.NoError(t, err)
+	defer tv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	series, err := tv.CreateSeriesContext(ctx, "NASDAQ:MSFT", 10, "5", nil)
+	require.NoError(t, err)
+	study, err := tv.AddStudyContext(ctx, series, volumeStudy, map[string]any{"length": 20})
+	require.NoError(t, err)
+	require.Equal(t, series, study.Series())
+
+	m := server.WaitFor(t, "create_study")
+	require.Equal(t, []any{tv.chartSessionID, study.ID(), "st1", series.ID(), volumeStudy, map[string]any{"length": 20.0}}, m.Payload)
+
+	rows := <-received
+	require.Len(t, rows, 2)
+	require.Equal(t, 0, rows[0].Index)
+	require.Equal(t, int64(1716290100), rows[0].Time)
+	require.Equal(t, 100.0, rows[0].Value(0))
+	require.True(t, math.IsNaN(rows[0].Value(1)))
+	require.Equal(t, 0.0, rows[0].Value(2))
+	require.True(t, math.IsNaN(rows[0].Value(3)))
+	require.Equal(t, StudyRow{Index: 1, Time: 1716290400, Values: []float64{200, 150, 1}}, rows[1])
+
+	require.NoError(t, study.Remove())
+	m = server.WaitFor(t, "remove_study")
+	require.Equal(t, []any{tv.chartSessionID, study.ID()}, m.Payload)
+}
+
+func TestSocket_AddStudyError(t *testing.T) {
+	server := studyServer(t)
+	tv, err := ConnectWithOptions(context.Background(), WithURL(server.URL()))
+	require.NoError(t, err)
+	defer tv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	series, err := tv.CreateSeriesContext(ctx, "NASDAQ:MSFT", 10, "5", nil)
+	require.NoError(t, err)
+	_, err = tv.AddStudyContext(ctx, series, "Nope@tv-basicstudies-1", nil)
+	var seriesErr *SeriesError
+	require.ErrorAs(t, err, &seriesErr)
+	require.Equal(t, "study_error", seriesErr.Type)
+	require.Equal(t, "NASDAQ:MSFT", seriesErr.Symbol)
+	require.Equal(t, "unknown study Nope@tv-basicstudies-1", seriesErr.Message)
+	require.Empty(t, tv.activeStudies())
+}
+
+func TestSocket_AddStudySendError(t *testing.T) {
+	server := studyServer(t)
+	tv, err := ConnectWithOptions(context.Background(), WithURL(server.URL()))
+	require.NoError(t, err)
+	defer tv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	series, err := tv.CreateSeriesContext(ctx, "NASDAQ:MSFT", 10, "5", nil)
+	require.NoError(t, err)
+	require.NoError(t, tv.Close())
+	_, err = tv.AddStudy(series, "Volume@tv-basicstudies-246", nil)
+	require.ErrorIs(t, err, ErrConnectionClosed)
+	require.Empty(t, tv.activeStudies())
+}
+
+func TestSocket_StreamStudy(t *testing.T) {
+	server := studyServer(t)
+	tv, err := ConnectWithOptions(context.Background(), WithURL(server.URL()))
+	require.NoError(t, err)
+	defer tv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	series, err := tv.CreateSeriesContext(ctx, "NASDAQ:MSFT", 10, "5", nil)
+	require.NoError(t, err)
+	streamCtx, stop := context.WithCancel(ctx)
+	events, err := tv.StreamStudy(streamCtx, series, volumeStudy, nil)
+	require.NoError(t, err)
+
+	select {
+	case event := <-events:
+		require.Equal(t, "st1", event.StudyID)
+		require.Len(t, event.Rows, 2)
+	case <-ctx.Done():
+		t.Fatal("the study rows were not received")
+	}
+	stop()
+	server.WaitFor(t, "remove_study")
+	for range events {
+	}
+}
+
+func TestSocket_ReconnectRestoresStudies(t *testing.T) {
+	server := studyServer(t)
+	reconnected := make(chan int, 1)
+	tv, err := ConnectWithOptions(context.Background(),
+		WithURL(server.URL()),
+		WithReconnect(&ReconnectPolicy{InitialBackoff: 10 * time.Millisecond}, func(attempt int) {
+			reconnected <- attempt
+		}),
+	)
+	require.NoError(t, err)
+	defer tv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	series, err := tv.CreateSeriesContext(ctx, "NASDAQ:MSFT", 10, "5", nil)
+	require.NoError(t, err)
+	study, err := tv.AddStudyContext(ctx, series, volumeStudy, nil)
+	require.NoError(t, err)
+	server.WaitFor(t, "create_study")
+
+	server.Disconnect()
+	select {
+	case <-reconnected:
+	case <-ctx.Done():
+		t.Fatal("socket did not reconnect")
+	}
+	server.WaitFor(t, "create_series")
+	m := server.WaitFor(t, "create_study")
+	require.Equal(t, study.ID(), m.Payload[1])
+	require.Equal(t, series.ID(), m.Payload[3])
+}
+
+func TestParseTimeScaleUpdate_RecordedStudyRows(t *testing.T) {
+	rows := make(map[string][]StudyRow)
+	for _, name := range []string{"timescale_update", "du_qsd"} {
+		msgs, err := protocol.Decode(loadFrame(t, name))
+		require.NoError(t, err)
+		for _, msg := range msgs {
+			name, p, err := decodeEnvelope(msg.Payload)
+			require.NoError(t, err)
+			if name != "timescale_update" && name != "du" {
+				continue
+			}
+			update, err := parseTimeScaleUpdate(p)
+			require.NoError(t, err)
+			for id, r := range update.studies {
+				rows[id] = append(rows[id], r...)
+			}
+		}
+	}
+
+	// st10 is delivered inside the timescale_update along with the bars
+	require.NotEmpty(t, rows["st10"])
+	require.Equal(t, -1000100, rows["st10"][0].Index)
+
+	st8 := rows["st8"]
+	require.NotEmpty(t, st8)
+	require.Equal(t, -1000100, st8[0].Index)
+	require.Equal(t, int64(1716229500), st8[0].Time)
+	require.Equal(t, 424.48799999999846, st8[0].Value(0))
+	require.Equal(t, 0.0, st8[0].Value(1))
+	require.True(t, math.IsNaN(st8[0].Value(6)))
+	for _, row := range st8 {
+		for _, v := range row.Values {
+			require.NotEqual(t, studyNoValue, v)
+		}
+	}
+}
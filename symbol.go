@@ -0,0 +1,150 @@
+package tvsocket
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// SymbolInfo is the symbol metadata of a symbol_resolved message
+type SymbolInfo struct {
+	Name            string       `mapstructure:"name"`
+	FullName        string       `mapstructure:"full_name"`
+	ProName         string       `mapstructure:"pro_name"`
+	Description     string       `mapstructure:"description"`
+	Exchange        string       `mapstructure:"exchange"`
+	ListedExchange  string       `mapstructure:"listed_exchange"`
+	Type            string       `mapstructure:"type"`
+	Currency        string       `mapstructure:"currency_code"`
+	Timezone        string       `mapstructure:"timezone"`
+	Session         string       `mapstructure:"session"`
+	SessionHolidays string       `mapstructure:"session_holidays"`
+	PriceScale      int          `mapstructure:"pricescale"`
+	MinMov          int          `mapstructure:"minmov"`
+	PointValue      float64      `mapstructure:"pointvalue"`
+	TypeSpecs       []string     `mapstructure:"typespecs"`
+	Subsessions     []Subsession `mapstructure:"subsessions"`
+}
+
+// Subsession is a trading session of the symbol, e.g. regular or premarket
+type Subsession struct {
+	ID          string `mapstructure:"id"`
+	Description string `mapstructure:"description"`
+	Session     string `mapstructure:"session"`
+	Private     bool   `mapstructure:"private"`
+}
+
+// symbolResolved is decoded from symbol_resolved messages
+type symbolResolved struct {
+	symbolID string
+	info     *SymbolInfo
+}
+
+// resolveResult answers a pending ResolveSymbol
+type resolveResult struct {
+	info *SymbolInfo
+	err  error
+}
+
+// ResolveSymbol returns the metadata of the symbol.
+// An unknown symbol is reported as a *SeriesError matching ErrSymbolNotFound.
+// Called from a callback it fails with ErrCalledFromCallback.
+func (s *Socket) ResolveSymbol(ctx context.Context, symbol string) (info *SymbolInfo, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	if err = s.checkNotInLoop(); err != nil {
+		return
+	}
+	resolved := make(chan resolveResult, 1)
+	s.mu.Lock()
+	s.resolveCounter++
+	id := "ss_" + strconv.Itoa(s.resolveCounter)
+	if s.resolvers == nil {
+		s.resolvers = make(map[string]chan resolveResult)
+	}
+	s.resolvers[id] = resolved
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.resolvers, id)
+		s.mu.Unlock()
+	}()
+
+	err = s.sendSocketMessage(getSocketMessage("resolve_symbol", []any{s.chartSessionID, id, symbolArg(symbol)}))
+	if err != nil {
+		return
+	}
+	select {
+	case r := <-resolved:
+		if seriesErr, ok := r.err.(*SeriesError); ok {
+			seriesErr.Symbol = symbol
+		}
+		return r.info, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-s.ctx.Done():
+		return nil, newError(ErrConnectionClosed, ResolveSymbolErrorContext, nil)
+	}
+}
+
+// symbolArg is the symbol argument of resolve_symbol, a SymbolSpec string is sent as is
+func symbolArg(symbol string) string {
+	if isSymbolSpec(symbol) {
+		return symbol
+	}
+	return SymbolSpec{Symbol: symbol}.String()
+}
+
+// parseSymbolResolved decodes the symbol id and the metadata of a symbol_resolved message
+func parseSymbolResolved(msg *SocketMessage) (resolved *symbolResolved, err error) {
+	p, ok := msg.Payload.([]any)
+	if !ok || len(p) < 3 {
+		err = errors.New("There is something wrong with the symbol_resolved payload")
+		return
+	}
+	id, _ := p[1].(string)
+	var info *SymbolInfo
+	if err = mapstructure.Decode(p[2], &info); err != nil {
+		return
+	}
+	return &symbolResolved{symbolID: id, info: info}, nil
+}
+
+func (s *Socket) onSymbolResolved(resolved *symbolResolved) {
+	if s.answerResolver(resolved.symbolID, resolveResult{info: resolved.info}) {
+		return
+	}
+	series := s.findSeries(resolved.symbolID)
+	if series == nil {
+		return
+	}
+	s.mu.Lock()
+	series.info = resolved.info
+	s.mu.Unlock()
+}
+
+// answerResolver delivers the result to the ResolveSymbol waiting for the symbol id
+func (s *Socket) answerResolver(id string, r resolveResult) bool {
+	s.mu.Lock()
+	resolved, ok := s.resolvers[id]
+	delete(s.resolvers, id)
+	s.mu.Unlock()
+	if ok {
+		resolved <- r
+	}
+	return ok
+}
+
+// dropResolvers ends the pending ResolveSymbol calls
+func (s *Socket) dropResolvers(err error) {
+	s.mu.Lock()
+	resolvers := s.resolvers
+	s.resolvers = nil
+	s.mu.Unlock()
+	for _, resolved := range resolvers {
+		resolved <- resolveResult{err: err}
+	}
+}
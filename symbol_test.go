@@ -0,0 +1,149 @@
+package tvsocket
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ivo100/tvsocket/tvtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSocket_ResolveSymbol(t *testing.T) {
+	server := tvtest.NewServer(t)
+	server.SetSymbolInfo("NASDAQ:AAPL", map[string]any{
+		"name":             "AAPL",
+		"full_name":        "NASDAQ:AAPL",
+		"description":      "Apple Inc.",
+		"exchange":         "NASDAQ",
+		"type":             "stock",
+		"currency_code":    "USD",
+		"timezone":         "America/New_York",
+		"session":          "0930-1600",
+		"session_holidays": "20240101,20240115",
+		"pricescale":       100,
+		"minmov":           1,
+		"pointvalue":       1,
+		"typespecs":        []string{"common"},
+		"subsessions": []map[string]any{
+			{"id": "regular", "description": "Regular Trading Hours", "session": "0930-1600", "private": false},
+			{"id": "premarket", "description": "Premarket", "session": "0400-0930", "private": true},
+		},
+		"has_intraday": true,
+	})
+	server.SetSymbolError("NASDAQ:NOPE", "invalid symbol")
+	tv, err := ConnectWithOptions(context.Background(), WithURL(server.URL()))
+	require.NoError(t, err)
+	defer tv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	info, err := tv.ResolveSymbol(ctx, "NASDAQ:AAPL")
+	require.NoError(t, err)
+	require.Equal(t, &SymbolInfo{
+		Name:            "AAPL",
+		FullName:        "NASDAQ:AAPL",
+		Description:     "Apple Inc.",
+		Exchange:        "NASDAQ",
+		Type:            "stock",
+		Currency:        "USD",
+		Timezone:        "America/New_York",
+		Session:         "0930-1600",
+		SessionHolidays: "20240101,20240115",
+		PriceScale:      100,
+		MinMov:          1,
+		PointValue:      1,
+		TypeSpecs:       []string{"common"},
+		Subsessions: []Subsession{
+			{ID: "regular", Description: "Regular Trading Hours", Session: "0930-1600"},
+			{ID: "premarket", Description: "Premarket", Session: "0400-0930", Private: true},
+		},
+	}, info)
+
+	_, err = tv.ResolveSymbol(ctx, "NASDAQ:NOPE")
+	require.ErrorIs(t, err, ErrSymbolNotFound)
+	var seriesErr *SeriesError
+	require.ErrorAs(t, err, &seriesErr)
+	require.Equal(t, "NASDAQ:NOPE", seriesErr.Symbol)
+	require.Equal(t, "invalid symbol", seriesErr.Message)
+}
+
+func TestSocket_ResolveSymbolClosed(t *testing.T) {
+	server := tvtest.NewServer(t)
+	tv, err := ConnectWithOptions(context.Background(), WithURL(server.URL()))
+	require.NoError(t, err)
+
+	resolved := make(chan error, 1)
+	go func() {
+		_, err := tv.ResolveSymbol(context.Background(), "NASDAQ:AAPL")
+		resolved <- err
+	}()
+	server.WaitFor(t, "resolve_symbol")
+	require.NoError(t, tv.Close())
+	// the reply may have been dispatched before the socket was closed
+	if err = <-resolved; err != nil {
+		require.ErrorIs(t, err, ErrConnectionClosed)
+	}
+}
+
+func TestSeries_Info(t *testing.T) {
+	server := tvtest.NewServer(t)
+	server.SetBars("NASDAQ:MSFT", tvtest.Bar{Time: 1716290100, Open: 1, High: 2, Low: 0.5, Close: 1.5, Volume: 100})
+	tv, err := ConnectWithOptions(context.Background(), WithURL(server.URL()))
+	require.NoError(t, err)
+	defer tv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	series, err := tv.CreateSeriesContext(ctx, "NASDAQ:MSFT", 10, "5", nil)
+	require.NoError(t, err)
+	require.Equal(t, "MSFT", series.Info().Name)
+	require.Equal(t, "NASDAQ", series.Info().Exchange)
+}
+
+func TestSymbolSpec_String(t *testing.T) {
+	settlementAsClose := true
+	require.Equal(t, `={"symbol":"NASDAQ:MSFT"}`, SymbolSpec{Symbol: "NASDAQ:MSFT"}.String())
+	require.Equal(t,
+		`={"adjustment":"dividends","currency-id":"USD","session":"extended","symbol":"BATS:MSFT"}`,
+		SymbolSpec{Symbol: "BATS:MSFT", Adjustment: AdjustmentDividends, Session: SessionExtended, CurrencyID: "USD"}.String(),
+	)
+	require.Equal(t,
+		`={"adjustment":"none","backadjustment":"default","settlement-as-close":true,"symbol":"CME_MINI:ES1!"}`,
+		SymbolSpec{Symbol: "CME_MINI:ES1!", Adjustment: AdjustmentNone, SettlementAsClose: &settlementAsClose, BackAdjust: true}.String(),
+	)
+	settlementAsClose = false
+	require.Equal(t,
+		`={"adjustment":"dividends","settlement-as-close":false,"symbol":"CME:ES1!"}`,
+		SymbolSpec{Symbol: "CME:ES1!", Adjustment: AdjustmentDividends, SettlementAsClose: &settlementAsClose}.String(),
+	)
+	require.Equal(t, `={"symbol":"A\"B"}`, SymbolSpec{Symbol: `A"B`}.String())
+}
+
+func TestSymbolArg(t *testing.T) {
+	require.Equal(t, `={"symbol":"NASDAQ:MSFT"}`, symbolArg("NASDAQ:MSFT"))
+	require.Equal(t, `={"symbol":"A\"B\\C"}`, symbolArg(`A"B\C`))
+	spec := SymbolSpec{Symbol: "BATS:MSFT", Session: SessionExtended}.String()
+	require.Equal(t, spec, symbolArg(spec))
+}
+
+func TestSocket_SymbolSpecIsSentAsIs(t *testing.T) {
+	server := tvtest.NewServer(t)
+	server.SetBars("BATS:MSFT", tvtest.Bar{Time: 1716290100, Close: 1})
+	tv, err := ConnectWithOptions(context.Background(), WithURL(server.URL()))
+	require.NoError(t, err)
+	defer tv.Close()
+
+	spec := SymbolSpec{Symbol: "BATS:MSFT", Adjustment: AdjustmentDividends, Session: SessionExtended}.String()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	bars, err := tv.GetBars(ctx, spec, "1D", 10)
+	require.NoError(t, err)
+	require.Len(t, bars, 1)
+	m := server.WaitFor(t, "resolve_symbol")
+	require.Equal(t, spec, m.Payload[2])
+
+	require.NoError(t, tv.AddSymbol(spec))
+	m = server.WaitFor(t, "quote_add_symbols")
+	require.Equal(t, []any{tv.quoteSessionID, spec}, m.Payload)
+}
@@ -0,0 +1,72 @@
+package tvsocket
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Adjustment tells how the prices of a symbol are adjusted for corporate actions
+type Adjustment string
+
+const (
+	// AdjustmentSplits adjusts the prices for splits, the default of the server
+	AdjustmentSplits Adjustment = "splits"
+	// AdjustmentDividends adjusts the prices for splits and dividends
+	AdjustmentDividends Adjustment = "dividends"
+	// AdjustmentNone leaves the prices unadjusted
+	AdjustmentNone Adjustment = "none"
+)
+
+// Session selects the trading hours of the bars
+type Session string
+
+const (
+	// SessionRegular keeps the regular trading hours, the default of the server
+	SessionRegular Session = "regular"
+	// SessionExtended adds the premarket and postmarket bars
+	SessionExtended Session = "extended"
+)
+
+// SymbolSpec is a symbol along with the options of its prices, as the web client sends it.
+// Its String form, ={"symbol": ...}, is accepted wherever the socket takes a symbol: the symbols starting with =
+// are sent as they are to resolve_symbol, quote_add_symbols and quote_fast_symbols.
+// The zero value of an option leaves it to the server.
+type SymbolSpec struct {
+	Symbol     string
+	Adjustment Adjustment
+	Session    Session
+	// CurrencyID converts the prices to the currency, e.g. USD
+	CurrencyID string
+	// SettlementAsClose tells whether the settlement price of futures is the close of daily bars, nil leaves it to the server
+	SettlementAsClose *bool
+	// BackAdjust removes the gaps between the contracts of a continuous futures symbol
+	BackAdjust bool
+}
+
+// String returns the spec in the ={...} form of the wire, with the keys in the order of the web client
+func (sp SymbolSpec) String() string {
+	// a map is marshalled with sorted keys
+	spec := map[string]any{"symbol": sp.Symbol}
+	if sp.Adjustment != "" {
+		spec["adjustment"] = sp.Adjustment
+	}
+	if sp.Session != "" {
+		spec["session"] = sp.Session
+	}
+	if sp.CurrencyID != "" {
+		spec["currency-id"] = sp.CurrencyID
+	}
+	if sp.SettlementAsClose != nil {
+		spec["settlement-as-close"] = *sp.SettlementAsClose
+	}
+	if sp.BackAdjust {
+		spec["backadjustment"] = "default"
+	}
+	b, _ := json.Marshal(spec)
+	return "=" + string(b)
+}
+
+// isSymbolSpec reports whether the symbol is already in the ={...} form
+func isSymbolSpec(symbol string) bool {
+	return strings.HasPrefix(symbol, "=")
+}
@@ -0,0 +1,473 @@
+// Package tvtest provides an in-process stand-in for the TradingView socket, so code built on
+// tvsocket can be tested offline.
+//
+// The server sends the session hello, records every message of the clients and answers
+// quote_add_symbols, resolve_symbol, create_series, modify_series, request_more_data and create_study with the quotes,
+// symbol metadata, bars and study rows scripted by SetQuote, SetSymbolInfo, SetBars and SetStudy.
+// Anything else can be injected with Send, SendError, Disconnect and RejectConnections.
+package tvtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ivo100/tvsocket/protocol"
+)
+
+// Hello is the session message sent right after the websocket handshake
+const Hello = `{"session_id":"<0.1.2>","timestamp":1716413304}`
+
+// WaitTimeout bounds WaitFor
+var WaitTimeout = 5 * time.Second
+
+// Message is a message received from a client
+type Message struct {
+	Name    string `json:"m"`
+	Payload []any  `json:"p"`
+}
+
+// Bar is a scripted bar of a series
+type Bar struct {
+	Time   int64
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Volume float64
+}
+
+// StudyRow is a scripted row of a study, NoValue marks a plot without value
+type StudyRow struct {
+	Time   int64
+	Values []float64
+}
+
+// NoValue is sent by the server for a study plot without value at a bar
+const NoValue = 1e100
+
+// Server is the fake TradingView socket
+type Server struct {
+	server     *httptest.Server
+	mu         sync.Mutex
+	conns      []*conn
+	quotes     map[string]map[string]any
+	bars       map[string][]Bar
+	studies    map[string][]StudyRow
+	infos      map[string]map[string]any
+	symbolErrs map[string]string
+	reject     bool
+	headers    chan http.Header
+	messages   chan Message
+	heartbeats chan string
+}
+
+// conn is a client connection along with the sessions it created
+type conn struct {
+	ws *websocket.Conn
+	// wmu serializes the writes of the handler and of the test
+	wmu            sync.Mutex
+	chartSessionID string
+	quoteSessionID string
+	// symbols maps the symbol ids of resolve_symbol to their symbols
+	symbols map[string]string
+	// series maps the series ids to the scripted bars they received
+	series map[string]*served
+}
+
+// served tracks the scripted bars sent to a series, older bars are sent with lower indexes
+type served struct {
+	symbol     string
+	turnaround string
+	// count is the number of bars asked by create_series, 0 for all of them
+	count int
+	// first is the position in the scripted bars of the oldest bar sent, index the index it was sent with
+	first int
+	index int
+}
+
+// NewServer starts a server that is closed when the test ends
+func NewServer(tb testing.TB) *Server {
+	s := &Server{
+		quotes:     make(map[string]map[string]any),
+		bars:       make(map[string][]Bar),
+		studies:    make(map[string][]StudyRow),
+		infos:      make(map[string]map[string]any),
+		symbolErrs: make(map[string]string),
+		headers:    make(chan http.Header, 10),
+		messages:   make(chan Message, 1000),
+		heartbeats: make(chan string, 1000),
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	tb.Cleanup(s.Close)
+	return s
+}
+
+// URL returns the websocket url of the server
+func (s *Server) URL() string {
+	return "ws" + strings.TrimPrefix(s.server.URL, "http")
+}
+
+// Close disconnects the clients and shuts the server down
+func (s *Server) Close() {
+	s.Disconnect()
+	s.server.Close()
+}
+
+// Headers returns the request headers of every handshake
+func (s *Server) Headers() <-chan http.Header {
+	return s.headers
+}
+
+// Heartbeats returns the heartbeats echoed by the clients, e.g. ~h~1
+func (s *Server) Heartbeats() <-chan string {
+	return s.heartbeats
+}
+
+// Messages returns every message received from the clients
+func (s *Server) Messages() <-chan Message {
+	return s.messages
+}
+
+// WaitFor returns the next received message with the given name, the messages before it are discarded
+func (s *Server) WaitFor(tb testing.TB, name string) Message {
+	tb.Helper()
+	timeout := time.After(WaitTimeout)
+	for {
+		select {
+		case m := <-s.messages:
+			if m.Name == name {
+				return m
+			}
+		case <-timeout:
+			tb.Fatalf("timed out waiting for %s", name)
+			return Message{}
+		}
+	}
+}
+
+// SetQuote scripts the qsd sent when a client adds the symbol to its quote session
+func (s *Server) SetQuote(symbol string, fields map[string]any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.quotes[symbol] = fields
+}
+
+// SetBars scripts the history of the symbol. A series of the symbol receives its last bars
+// in a timescale_update followed by series_completed.
+func (s *Server) SetBars(symbol string, bars ...Bar) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bars[symbol] = bars
+}
+
+// SetStudy scripts the rows of the study, e.g. Volume@tv-basicstudies-246, whatever its inputs and series.
+// create_study is answered with the rows in a du followed by study_completed, and unscripted studies with study_error.
+func (s *Server) SetStudy(studyID string, rows ...StudyRow) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.studies[studyID] = rows
+}
+
+// SetSymbolInfo scripts the symbol_resolved metadata of the symbol.
+// Symbols without metadata are resolved with their name and exchange only.
+func (s *Server) SetSymbolInfo(symbol string, info map[string]any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.infos[symbol] = info
+}
+
+// SetSymbolError scripts the symbol_error answering every resolve_symbol of the symbol
+func (s *Server) SetSymbolError(symbol string, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.symbolErrs[symbol] = reason
+}
+
+// Send writes the payloads as a single frame to every connected client
+func (s *Server) Send(payloads ...string) {
+	var frame []byte
+	for _, payload := range payloads {
+		frame = append(frame, protocol.Encode(protocol.Message{Payload: []byte(payload)})...)
+	}
+	s.SendRaw(frame)
+}
+
+// SendRaw writes the frame as is to every connected client
+func (s *Server) SendRaw(frame []byte) {
+	for _, c := range s.connections() {
+		_ = c.write(frame)
+	}
+}
+
+// SendMessage marshals the message and sends it to every connected client
+func (s *Server) SendMessage(name string, payload ...any) {
+	for _, c := range s.connections() {
+		_ = c.send(name, payload...)
+	}
+}
+
+// SendError sends an error message such as critical_error or protocol_error to every connected client
+func (s *Server) SendError(name string, payload ...any) {
+	s.SendMessage(name, payload...)
+}
+
+// Heartbeat sends the heartbeat ~h~n to every connected client
+func (s *Server) Heartbeat(n int) {
+	s.Send("~h~" + strconv.Itoa(n))
+}
+
+// Disconnect drops every client connection
+func (s *Server) Disconnect() {
+	s.mu.Lock()
+	conns := s.conns
+	s.conns = nil
+	s.mu.Unlock()
+	for _, c := range conns {
+		_ = c.ws.Close()
+	}
+}
+
+// RejectConnections makes the handshakes fail until it is called with false
+func (s *Server) RejectConnections(reject bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reject = reject
+}
+
+// Connections returns the number of connected clients
+func (s *Server) Connections() int {
+	return len(s.connections())
+}
+
+func (s *Server) connections() []*conn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*conn(nil), s.conns...)
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	reject := s.reject
+	s.mu.Unlock()
+	if reject {
+		http.Error(w, "connection rejected", http.StatusServiceUnavailable)
+		return
+	}
+	upgrader := websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer ws.Close()
+	select {
+	case s.headers <- r.Header:
+	default:
+	}
+	c := &conn{ws: ws, symbols: make(map[string]string), series: make(map[string]*served)}
+	if c.write(protocol.Encode(protocol.Message{Payload: []byte(Hello)})) != nil {
+		return
+	}
+	s.mu.Lock()
+	s.conns = append(s.conns, c)
+	s.mu.Unlock()
+
+	for {
+		_, frame, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		msgs, _ := protocol.Decode(frame)
+		for _, msg := range msgs {
+			if msg.IsHeartbeat() {
+				s.heartbeats <- string(msg.Payload)
+				continue
+			}
+			var m Message
+			if json.Unmarshal(msg.Payload, &m) != nil {
+				continue
+			}
+			s.messages <- m
+			s.reply(c, m)
+		}
+	}
+}
+
+// reply answers the message with the scripted data
+func (s *Server) reply(c *conn, m Message) {
+	switch m.Name {
+	case "chart_create_session":
+		c.chartSessionID = arg(m, 0)
+	case "quote_create_session":
+		c.quoteSessionID = arg(m, 0)
+	case "quote_add_symbols":
+		for i := 1; i < len(m.Payload); i++ {
+			symbol := arg(m, i)
+			s.mu.Lock()
+			fields, ok := s.quotes[symbol]
+			s.mu.Unlock()
+			if ok {
+				_ = c.send("qsd", c.quoteSessionID, map[string]any{"n": symbol, "s": "ok", "v": fields})
+			}
+		}
+	case "resolve_symbol":
+		s.replySymbol(c, arg(m, 1), symbolOf(arg(m, 2)))
+	case "create_series":
+		count := 0
+		if len(m.Payload) > 5 {
+			if n, isNumber := m.Payload[5].(float64); isNumber {
+				count = int(n)
+			}
+		}
+		s.replySeries(c, arg(m, 1), arg(m, 2), arg(m, 3), count)
+	case "modify_series":
+		count := 0
+		if sent, ok := c.series[arg(m, 1)]; ok {
+			count = sent.count
+		}
+		s.replySeries(c, arg(m, 1), arg(m, 2), arg(m, 3), count)
+	case "request_more_data":
+		s.replyMoreData(c, arg(m, 1), m.Payload)
+	case "create_study":
+		s.replyStudy(c, arg(m, 1), arg(m, 2), arg(m, 4))
+	}
+}
+
+func (s *Server) replySymbol(c *conn, symbolID string, symbol string) {
+	c.symbols[symbolID] = symbol
+	s.mu.Lock()
+	reason, rejected := s.symbolErrs[symbol]
+	info, ok := s.infos[symbol]
+	s.mu.Unlock()
+	if rejected {
+		_ = c.send("symbol_error", c.chartSessionID, symbolID, reason)
+		return
+	}
+	if !ok {
+		exchange, name, _ := strings.Cut(symbol, ":")
+		if name == "" {
+			exchange, name = "", exchange
+		}
+		info = map[string]any{"name": name, "full_name": symbol, "pro_name": symbol, "exchange": exchange}
+	}
+	_ = c.send("symbol_resolved", c.chartSessionID, symbolID, info)
+}
+
+// replySeries sends the last count bars of the symbol, the history of a series created or modified
+func (s *Server) replySeries(c *conn, seriesID string, turnaround string, symbolID string, count int) {
+	symbol := c.symbols[symbolID]
+	s.mu.Lock()
+	bars, ok := s.bars[symbol]
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+	first := 0
+	if count > 0 && count < len(bars) {
+		first = len(bars) - count
+	}
+	sent := &served{symbol: symbol, turnaround: turnaround, count: count, first: first}
+	c.series[seriesID] = sent
+	c.sendBars(seriesID, sent, bars[first:], 0)
+}
+
+// replyMoreData sends the scripted bars older than the ones the series received, an empty update once there are none
+func (s *Server) replyMoreData(c *conn, seriesID string, payload []any) {
+	sent, ok := c.series[seriesID]
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	bars := s.bars[sent.symbol]
+	s.mu.Unlock()
+	count := 0
+	if len(payload) > 2 {
+		if n, isNumber := payload[2].(float64); isNumber {
+			count = int(n)
+		}
+	}
+	first := max(0, min(sent.first, len(bars))-count)
+	older := bars[first:min(sent.first, len(bars))]
+	sent.index -= len(older)
+	sent.first = first
+	c.sendBars(seriesID, sent, older, sent.index)
+}
+
+// sendBars sends the bars in a timescale_update, numbered from index, followed by series_completed
+func (c *conn) sendBars(seriesID string, sent *served, bars []Bar, index int) {
+	rows := make([]any, 0, len(bars))
+	for i, bar := range bars {
+		rows = append(rows, map[string]any{
+			"i": index + i,
+			"v": []any{float64(bar.Time), bar.Open, bar.High, bar.Low, bar.Close, bar.Volume},
+		})
+	}
+	_ = c.send("timescale_update", c.chartSessionID, map[string]any{
+		seriesID: map[string]any{"s": rows, "t": sent.turnaround},
+	})
+	_ = c.send("series_completed", c.chartSessionID, seriesID, "streaming", sent.turnaround)
+}
+
+func (s *Server) replyStudy(c *conn, id string, turnaround string, studyID string) {
+	s.mu.Lock()
+	rows, ok := s.studies[studyID]
+	s.mu.Unlock()
+	if !ok {
+		_ = c.send("study_error", c.chartSessionID, id, turnaround, "unknown study "+studyID)
+		return
+	}
+	st := make([]any, 0, len(rows))
+	for i, row := range rows {
+		st = append(st, map[string]any{
+			"i": i,
+			"v": append([]float64{float64(row.Time)}, row.Values...),
+		})
+	}
+	_ = c.send("du", c.chartSessionID, map[string]any{
+		id: map[string]any{"st": st, "ns": map[string]any{"d": "", "indexes": []any{}}, "t": turnaround},
+	})
+	_ = c.send("study_completed", c.chartSessionID, id, turnaround)
+}
+
+func (c *conn) send(name string, payload ...any) error {
+	data, err := json.Marshal(Message{Name: name, Payload: payload})
+	if err != nil {
+		return err
+	}
+	return c.write(protocol.Encode(protocol.Message{Payload: data}))
+}
+
+func (c *conn) write(frame []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return c.ws.WriteMessage(websocket.TextMessage, frame)
+}
+
+// arg returns the i-th payload argument when it is a string
+func arg(m Message, i int) string {
+	if i >= len(m.Payload) {
+		return ""
+	}
+	v, _ := m.Payload[i].(string)
+	return v
+}
+
+// symbolOf extracts the symbol of a resolve_symbol argument such as ={"symbol": "NASDAQ:MSFT"}
+func symbolOf(arg string) string {
+	if !strings.HasPrefix(arg, "=") {
+		return arg
+	}
+	var spec struct {
+		Symbol string `json:"symbol"`
+	}
+	if json.Unmarshal([]byte(arg[1:]), &spec) != nil {
+		return arg
+	}
+	return spec.Symbol
+}
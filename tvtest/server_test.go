@@ -0,0 +1,112 @@
+package tvtest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ivo100/tvsocket"
+	"github.com/ivo100/tvsocket/tvtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_ScriptedBars(t *testing.T) {
+	server := tvtest.NewServer(t)
+	server.SetBars("NASDAQ:MSFT",
+		tvtest.Bar{Time: 1716290100, Open: 1, High: 2, Low: 0.5, Close: 1.5, Volume: 100},
+		tvtest.Bar{Time: 1716290400, Open: 1.5, High: 3, Low: 1, Close: 2.5, Volume: 200},
+		tvtest.Bar{Time: 1716290700, Open: 2.5, High: 4, Low: 2, Close: 3.5, Volume: 300},
+	)
+	tv, err := tvsocket.ConnectWithOptions(context.Background(), tvsocket.WithURL(server.URL()))
+	require.NoError(t, err)
+	defer tv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	bars, err := tv.GetBars(ctx, "NASDAQ:MSFT", "5", 2)
+	require.NoError(t, err)
+	require.Equal(t, []tvsocket.TOHLCV{
+		{Time: 1716290400, Open: 1.5, High: 3, Low: 1, Close: 2.5, Volume: 200},
+		{Time: 1716290700, Open: 2.5, High: 4, Low: 2, Close: 3.5, Volume: 300},
+	}, bars)
+}
+
+func TestServer_ScriptedSymbolError(t *testing.T) {
+	server := tvtest.NewServer(t)
+	server.SetSymbolError("NASDAQ:NOPE", "invalid symbol")
+	tv, err := tvsocket.ConnectWithOptions(context.Background(), tvsocket.WithURL(server.URL()))
+	require.NoError(t, err)
+	defer tv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = tv.GetBars(ctx, "NASDAQ:NOPE", "5", 10)
+	require.ErrorIs(t, err, tvsocket.ErrSymbolNotFound)
+}
+
+func TestServer_ScriptedQuote(t *testing.T) {
+	server := tvtest.NewServer(t)
+	server.SetQuote("NASDAQ:MSFT", map[string]any{"lp": 420.5, "ch": 1.25})
+	tv, err := tvsocket.ConnectWithOptions(context.Background(), tvsocket.WithURL(server.URL()))
+	require.NoError(t, err)
+	defer tv.Close()
+
+	quotes, err := tv.Subscribe(context.Background(), "NASDAQ:MSFT")
+	require.NoError(t, err)
+	select {
+	case event := <-quotes:
+		require.Equal(t, "NASDAQ:MSFT", event.Symbol)
+		require.Equal(t, 420.5, *event.Data.Price)
+		require.Equal(t, 1.25, *event.Data.Change)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the quote was not received")
+	}
+}
+
+func TestServer_InjectedFailures(t *testing.T) {
+	server := tvtest.NewServer(t)
+	reported := make(chan error, 10)
+	reconnected := make(chan int, 10)
+	policy := tvsocket.DefaultReconnectPolicy()
+	policy.InitialBackoff = 10 * time.Millisecond
+	tv, err := tvsocket.ConnectWithOptions(context.Background(),
+		tvsocket.WithURL(server.URL()),
+		tvsocket.WithOnError(func(err error, context string) {
+			reported <- err
+		}),
+		tvsocket.WithReconnect(policy, func(attempt int) {
+			reconnected <- attempt
+		}),
+	)
+	require.NoError(t, err)
+	defer tv.Close()
+	server.WaitFor(t, "quote_set_fields")
+
+	server.SendError("critical_error", "cs_x", "invalid_parameters")
+	select {
+	case err = <-reported:
+		require.ErrorIs(t, err, tvsocket.ErrServerError)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the error was not reported")
+	}
+	// a server error leaves the connection open, a dropped connection is re-dialed
+	require.Equal(t, 1, server.Connections())
+	server.Disconnect()
+	select {
+	case <-reconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the socket did not reconnect")
+	}
+
+	server.RejectConnections(true)
+	server.Disconnect()
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, 0, server.Connections())
+	server.RejectConnections(false)
+	select {
+	case <-reconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the socket did not reconnect")
+	}
+	require.Equal(t, 1, server.Connections())
+}
@@ -1,6 +1,7 @@
 package tvsocket
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -15,6 +16,14 @@ type SocketInterface interface {
 	RequestQuotes(symbol string, bars int, interval string, resultCallback OnReceiveQuoteCallback) error
 }
 
+// SocketContextInterface adds the context-aware methods of *Socket to SocketInterface
+type SocketContextInterface interface {
+	SocketInterface
+	InitContext(ctx context.Context, fields ...string) error
+	CloseContext(ctx context.Context) error
+	RequestQuotesContext(ctx context.Context, symbol string, bars int, interval string, resultCallback OnReceiveQuoteCallback) error
+}
+
 type TOHLCV struct {
 	Time   int64   `json:"time"`
 	Open   float64 `json:"open"`
@@ -60,6 +69,48 @@ type QuoteData struct {
 	Ask               *float64 `mapstructure:"ask"`
 	Change            *float64 `mapstructure:"ch"`
 	Time              *int64   `mapstructure:"lp_time"`
+	ChangePercent     *float64 `mapstructure:"chp"`
+	BidSize           *float64 `mapstructure:"bid_size"`
+	AskSize           *float64 `mapstructure:"ask_size"`
+	// extended hours price and change
+	ExtendedPrice         *float64 `mapstructure:"rtc"`
+	ExtendedTime          *int64   `mapstructure:"rtc_time"`
+	ExtendedChange        *float64 `mapstructure:"rch"`
+	ExtendedChangePercent *float64 `mapstructure:"rchp"`
+	AllTimeHigh           *float64 `mapstructure:"all_time_high"`
+	AllTimeLow            *float64 `mapstructure:"all_time_low"`
+	// symbol metadata, usually sent once after quote_add_symbols
+	Description            *string      `mapstructure:"description"`
+	ShortName              *string      `mapstructure:"short_name"`
+	ProName                *string      `mapstructure:"pro_name"`
+	OriginalName           *string      `mapstructure:"original_name"`
+	Exchange               *string      `mapstructure:"exchange"`
+	ListedExchange         *string      `mapstructure:"listed_exchange"`
+	Type                   *string      `mapstructure:"type"`
+	TypeSpecs              []string     `mapstructure:"typespecs"`
+	CurrencyCode           *string      `mapstructure:"currency_code"`
+	Timezone               *string      `mapstructure:"timezone"`
+	CurrentSession         *string      `mapstructure:"current_session"`
+	UpdateMode             *string      `mapstructure:"update_mode"`
+	Fractional             *bool        `mapstructure:"fractional"`
+	PriceScale             *int64       `mapstructure:"pricescale"`
+	MinMov                 *int64       `mapstructure:"minmov"`
+	PointValue             *float64     `mapstructure:"pointvalue"`
+	MarketCapBasic         *float64     `mapstructure:"market_cap_basic"`
+	TotalSharesOutstanding *float64     `mapstructure:"total_shares_outstanding"`
+	Source2                *QuoteSource `mapstructure:"source2"`
+	// Extra holds the fields without a typed counterpart, e.g. the ones requested through Init(fields...)
+	Extra map[string]any `mapstructure:",remain"`
+}
+
+// QuoteSource is the data provider of a quote
+type QuoteSource struct {
+	ID           string `mapstructure:"id"`
+	Name         string `mapstructure:"name"`
+	Description  string `mapstructure:"description"`
+	Country      string `mapstructure:"country"`
+	ExchangeType string `mapstructure:"exchange-type"`
+	URL          string `mapstructure:"url"`
 }
 
 // Flags ...
@@ -72,9 +123,21 @@ type OnReceiveDataCallback func(symbol string, data *QuoteData)
 
 type OnReceiveQuoteCallback func(symbol string, hloc []TOHLCV)
 
+// OnReceiveSnapshotCallback receives a copy of the merged quote, snapshot.Changed holds the fields changed by the qsd
+type OnReceiveSnapshotCallback func(snapshot QuoteSnapshot)
+
+// OnBarEventCallback is told which bars of a series opened, changed and closed, after OnReceiveQuoteCallback received them
+type OnBarEventCallback func(series *Series, event BarEvent)
+
+// OnReceiveStudyCallback receives the rows of a study, the whole history first and then the updates
+type OnReceiveStudyCallback func(study *Study, rows []StudyRow)
+
 // OnErrorCallback ...
 type OnErrorCallback func(err error, context string)
 
+// OnReconnectCallback is called once the connection has been restored, attempt starts at 1
+type OnReconnectCallback func(attempt int)
+
 func (q *QuoteData) String() string {
 	sb := new(strings.Builder)
 	if q.OpenPrice != nil {